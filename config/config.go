@@ -3,14 +3,18 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
-	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mattn/anko/env"
 
 	"github.com/0xERR0R/dns-mokka/mock"
+	"github.com/0xERR0R/dns-mokka/querylog"
+	"github.com/0xERR0R/dns-mokka/zone"
 	"github.com/mattn/anko/vm"
 	"github.com/sirupsen/logrus"
 
@@ -22,25 +26,110 @@ const (
 	prefix           = "MOKKA_"
 	envLogLevel      = prefix + "LOG_LEVEL"
 	envListenAddress = prefix + "LISTEN_ADDRESS"
+	envListeners     = prefix + "LISTENERS"
 	envRule          = prefix + "RULE_"
+	envFollowCNAME   = prefix + "FOLLOW_CNAME"
+	envCNAMEMaxDepth = prefix + "FOLLOW_CNAME_MAX_DEPTH"
+	envMultiQuestion = prefix + "MULTI_QUESTION"
+	envDoTAddress    = prefix + "DOT_LISTEN_ADDRESS"
+	envDoHAddress    = prefix + "DOH_LISTEN_ADDRESS"
+	envDoQAddress    = prefix + "DOQ_LISTEN_ADDRESS"
+	envTLSCertFile   = prefix + "TLS_CERT_FILE"
+	envTLSKeyFile    = prefix + "TLS_KEY_FILE"
+	envTLSCertOut    = prefix + "TLS_GENERATED_CERT_FILE"
+	envAdminAddress  = prefix + "ADMIN_LISTEN_ADDRESS"
+	envNotifyTargets = prefix + "NOTIFY_TARGETS"
+	envPcapFile      = prefix + "PCAP_FILE"
+	envUpstream      = prefix + "UPSTREAM"
 	tupleSize        = 2
+	anyType          = "*"
 )
 
-type RegexRule struct {
-	Regex *regexp.Regexp
-	Rule  string
+// handling of queries with multiple questions
+const (
+	// MultiQuestionFormErr rejects the query with FORMERR
+	MultiQuestionFormErr = "formerr"
+	// MultiQuestionFirst answers only the first question
+	MultiQuestionFirst = "first"
+	// MultiQuestionAll answers each question
+	MultiQuestionAll = "all"
+)
+
+type Rule struct {
+	Name string
+	// Types of questions the rule applies to, nil if the rule applies to all types ("*")
+	Types   []dns.Type
+	Matcher NameMatcher
+	Rule    string
+	// Clients restricts the rule to requests from these source networks (empty: any client)
+	Clients []*net.IPNet
+	// ECS restricts the rule to requests with an EDNS Client Subnet address in these networks
+	ECS []*net.IPNet
+	// Views restricts the rule to listeners with one of these views (empty: all listeners)
+	Views []string
+	// Opcode of the messages the rule applies to, default is dns.OpcodeQuery
+	Opcode int
+	// TSIGKeys restricts the rule to requests signed with one of these keys or any key ("*")
+	TSIGKeys []string
+	// TSIGError is a deliberate TSIG error of the response (empty: no error)
+	TSIGError string
+	// DNSSEC is the signature mode of the answers in signed zones (empty: valid signatures)
+	DNSSEC string
+	// RecordTypes are the types of the records returned by the rule, nil if the rule doesn't answer
+	// with NOERROR and records. Used to prove negative answers in signed zones.
+	RecordTypes []uint16
+	// UsesUpstream is set if the rule forwards the questions to the global upstream (FORWARD() without address)
+	UsesUpstream bool
 }
 
 type Config struct {
-	LogLevel      logrus.Level
-	ListenAddress string
-	Rules         map[dns.Type][]RegexRule
+	LogLevel  logrus.Level
+	Listeners []Listener
+	Rules     []Rule
+	// Defaults are applied to questions without matching rule, the first default rule which matches the type
+	// is used. NXDOMAIN is returned if no default rule matches.
+	Defaults []Rule
+	// FollowCNAME enables the resolution of CNAME targets with the own rules
+	FollowCNAME bool
+	// CNAMEMaxDepth is the maximal number of CNAME records in a chain
+	CNAMEMaxDepth int
+	// MultiQuestion defines the handling of queries with more than one question
+	MultiQuestion string
+	TLS           TLSConfig
+	// TSIGKeys are used to verify signed requests and to sign the responses
+	TSIGKeys []TSIGKey
+	// AdminAddress is the listening address of the admin HTTP API, disabled if empty
+	AdminAddress string
+	// NotifyTargets are the addresses (host:port) of the secondary servers which get NOTIFY messages
+	NotifyTargets []string
+	DNSSEC        DNSSECConfig
+	// QueryLog defines the log of the handled requests
+	QueryLog querylog.Options
+	// DNStap defines the output of the handled requests as dnstap messages
+	DNStap DNStapConfig
+	// PcapFile is the path of the capture file of the handled requests, disabled if empty
+	PcapFile string
+	// Upstream is the address of the resolver for FORWARD() without address and for questions without matching
+	// rule if no default response is defined
+	Upstream string
+}
+
+// TLSConfig contains the certificate for encrypted listeners
+type TLSConfig struct {
+	// CertFile and KeyFile contain the PEM encoded certificate and key, a self-signed certificate is generated if empty
+	CertFile string
+	KeyFile  string
+	// GeneratedCertFile is the path to write the generated self-signed certificate to (optional)
+	GeneratedCertFile string
 }
 
 func ReadConfig() (*Config, error) {
+	const defaultCNAMEMaxDepth = 8
+
 	c := &Config{
 		LogLevel:      logrus.InfoLevel,
-		ListenAddress: ":53",
+		CNAMEMaxDepth: defaultCNAMEMaxDepth,
+		MultiQuestion: MultiQuestionFormErr,
 	}
 
 	err := readEnv(c)
@@ -56,18 +145,69 @@ func readEnv(c *Config) error {
 		return err
 	}
 
-	if addr, found := os.LookupEnv(envListenAddress); found {
-		c.ListenAddress = addr
+	if c.Listeners, err = retrieveListenersFromEnv(); err != nil {
+		return err
+	}
+
+	if c.FollowCNAME, err = retrieveBoolFromEnv(envFollowCNAME, c.FollowCNAME); err != nil {
+		return err
+	}
+
+	if c.CNAMEMaxDepth, err = retrieveIntFromEnv(envCNAMEMaxDepth, c.CNAMEMaxDepth); err != nil {
+		return err
+	}
+
+	if c.MultiQuestion, err = retrieveMultiQuestionFromEnv(c.MultiQuestion); err != nil {
+		return err
+	}
+
+	if c.TLS, err = retrieveTLSConfigFromEnv(); err != nil {
+		return err
+	}
+
+	if c.TSIGKeys, err = retrieveTSIGKeysFromEnv(); err != nil {
+		return err
+	}
+
+	if c.DNSSEC, err = retrieveDNSSECConfigFromEnv(); err != nil {
+		return err
 	}
 
+	if c.QueryLog, err = retrieveQueryLogFromEnv(); err != nil {
+		return err
+	}
+
+	if c.DNStap, err = retrieveDNStapConfigFromEnv(); err != nil {
+		return err
+	}
+
+	c.AdminAddress = os.Getenv(envAdminAddress)
+	c.PcapFile = os.Getenv(envPcapFile)
+
+	if v := os.Getenv(envUpstream); v != "" {
+		c.Upstream = mock.UpstreamAddress(v)
+	}
+
+	c.NotifyTargets = retrieveNotifyTargetsFromEnv()
+
 	env, err := mock.CreateEnv()
 	if err != nil {
 		return fmt.Errorf("can't create env: %w", err)
 	}
 
-	c.Rules, err = retrieveRules(env)
+	if c.Rules, err = retrieveRules(env); err != nil {
+		return err
+	}
+
+	if c.Defaults, err = retrieveDefaultRules(env, c.Upstream); err != nil {
+		return err
+	}
+
+	if err := validateUpstream(c); err != nil {
+		return err
+	}
 
-	return err
+	return validateTSIGKeys(c)
 }
 
 func retrieveLogLevelFromEnv() (level logrus.Level, err error) {
@@ -84,62 +224,393 @@ func retrieveLogLevelFromEnv() (level logrus.Level, err error) {
 	return logrus.InfoLevel, nil
 }
 
-func retrieveRules(env *env.Env) (map[dns.Type][]RegexRule, error) {
-	rules := make(map[dns.Type][]RegexRule, 0)
+func retrieveMultiQuestionFromEnv(defaultMode string) (string, error) {
+	if mode, found := os.LookupEnv(envMultiQuestion); found {
+		switch mode {
+		case MultiQuestionFormErr, MultiQuestionFirst, MultiQuestionAll:
+			return mode, nil
+		default:
+			return "", fmt.Errorf("unknown multi question mode '%s'", mode)
+		}
+	}
+
+	return defaultMode, nil
+}
+
+func retrieveTLSConfigFromEnv() (TLSConfig, error) {
+	c := TLSConfig{
+		CertFile:          os.Getenv(envTLSCertFile),
+		KeyFile:           os.Getenv(envTLSKeyFile),
+		GeneratedCertFile: os.Getenv(envTLSCertOut),
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return TLSConfig{}, fmt.Errorf("both '%s' and '%s' must be defined", envTLSCertFile, envTLSKeyFile)
+	}
+
+	return c, nil
+}
+
+// retrieveNotifyTargetsFromEnv returns the comma separated addresses, the default port is 53
+func retrieveNotifyTargetsFromEnv() (targets []string) {
+	v := os.Getenv(envNotifyTargets)
+	if v == "" {
+		return nil
+	}
+
+	for _, t := range strings.Split(v, ",") {
+		t = strings.TrimSpace(t)
+
+		if _, _, err := net.SplitHostPort(t); err != nil {
+			t = net.JoinHostPort(strings.Trim(t, "[]"), "53")
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets
+}
+
+func retrieveBoolFromEnv(name string, defaultValue bool) (bool, error) {
+	if v, found := os.LookupEnv(name); found {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("can't parse '%s': %w", name, err)
+		}
+
+		return b, nil
+	}
+
+	return defaultValue, nil
+}
+
+func retrieveIntFromEnv(name string, defaultValue int) (int, error) {
+	if v, found := os.LookupEnv(name); found {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("can't parse '%s': %w", name, err)
+		}
+
+		return i, nil
+	}
 
+	return defaultValue, nil
+}
+
+func retrieveRules(env *env.Env) ([]Rule, error) {
 	ruleNames := retrieveRulesFromEnv()
 
 	sort.Strings(ruleNames)
 
+	rules := make([]Rule, 0, len(ruleNames))
+
 	for _, r := range ruleNames {
-		// example: A google.com/NOERROR(1.2.3.4)
-		rule := os.Getenv(r)
-		typeAddressRulePair := strings.SplitN(rule, "/", tupleSize)
+		rule, err := parseRule(env, strings.TrimPrefix(r, envRule), os.Getenv(r))
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parseRule parses the rule definition, for example: A,AAAA google.com [client=10.0.0.0/8]/NOERROR("A 1.2.3.4 20")
+func parseRule(env *env.Env, name, rule string) (Rule, error) {
+	if separator := strings.IndexAny(rule, " /"); separator < 0 || rule[separator] != ' ' {
+		return Rule{}, errors.New("rule should contain a space between types and name")
+	}
+
+	typeAddressPair := strings.SplitN(rule, " ", tupleSize)
+
+	address, options, fn, err := splitRule(typeAddressPair[1])
+	if err != nil {
+		return Rule{}, err
+	}
+
+	types, err := parseTypes(typeAddressPair[0])
+	if err != nil {
+		return Rule{}, err
+	}
+
+	r := Rule{
+		Name:  name,
+		Types: types,
+		Rule:  fn,
+	}
+
+	// the options are applied first, the check of the function depends on the opcode
+	matchKind, err := applyOptions(&r, options)
+	if err != nil {
+		return Rule{}, fmt.Errorf("can't parse options of rule '%s': %w", rule, err)
+	}
 
-		if len(typeAddressRulePair) != tupleSize {
-			return nil, errors.New("rule should contain '/'")
+	if err := checkFunction(env, &r); err != nil {
+		return Rule{}, fmt.Errorf("can't parse Rule '%s': %w", rule, err)
+	}
+
+	r.Matcher, err = NewMatcher(matchKind, address)
+	if err != nil {
+		if matchKind == MatchRegex {
+			return Rule{}, fmt.Errorf("can't parse Regex '%s': %w", rule, err)
 		}
 
-		typeAddressPair := strings.SplitN(typeAddressRulePair[0], " ", tupleSize)
+		return Rule{}, fmt.Errorf("can't parse pattern '%s': %w", rule, err)
+	}
 
-		rType, found := dns.StringToType[typeAddressPair[0]]
+	return r, nil
+}
+
+// checkFunction executes the function of the rule once to validate it and to determine the record types.
+// FORWARD() answers only queries, rules for other opcodes or zone transfers can't forward.
+func checkFunction(env *env.Env, r *Rule) error {
+	if _, err := parser.ParseSrc(r.Rule); err != nil {
+		return err
+	}
+
+	res, err := vm.Execute(env, nil, r.Rule)
+	if err != nil {
+		return fmt.Errorf("can't execute function: %w", err)
+	}
+
+	result, ok := res.(mock.Result)
+	if !ok {
+		return fmt.Errorf("function '%s' has no result", r.Rule)
+	}
+
+	if result.Err != nil {
+		return fmt.Errorf("can't execute function: %w", result.Err)
+	}
+
+	if len(result.ZoneFiles) > 0 {
+		if _, err := zone.LoadHistory(result.ZoneFiles...); err != nil {
+			return fmt.Errorf("can't load zone: %w", err)
+		}
+	}
+
+	if result.RCode == dns.RcodeSuccess && len(result.ZoneFiles) == 0 && !result.Drop && result.Forward == nil {
+		r.RecordTypes = recordTypes(result.RR, r.Types)
+	}
+
+	if result.Forward != nil && (r.Opcode != dns.OpcodeQuery || r.hasTransferType()) {
+		return errors.New("FORWARD() can't answer zone transfers, updates or notifications")
+	}
+
+	r.UsesUpstream = result.Forward != nil && result.Forward.Upstream == ""
+
+	return nil
+}
+
+// parseTypes parses a comma separated list of query types, "*" stands for all types
+func parseTypes(in string) ([]dns.Type, error) {
+	if in == anyType {
+		return nil, nil
+	}
+
+	var types []dns.Type
+
+	for _, t := range strings.Split(in, ",") {
+		rType, found := dns.StringToType[t]
 		if !found {
-			return nil, fmt.Errorf("unknown type '%s'", typeAddressPair[0])
+			return nil, fmt.Errorf("unknown type '%s'", t)
 		}
 
-		regex, err := regexp.Compile(strings.ToLower(typeAddressPair[1]))
-		if err != nil {
-			return nil, fmt.Errorf("can't parse Regex '%s': %w", rule, err)
+		types = append(types, dns.Type(rType))
+	}
+
+	return types, nil
+}
+
+// recordTypes returns the types of the records which are returned for the query types
+func recordTypes(records []mock.Record, types []dns.Type) []uint16 {
+	result := []uint16{}
+
+	for _, rr := range records {
+		t := dns.StringToType[rr.RType]
+
+		if !slices.Contains(result, t) && (types == nil || slices.Contains(types, dns.Type(t)) || t == dns.TypeCNAME) {
+			result = append(result, t)
 		}
+	}
 
-		fn := typeAddressRulePair[1]
-		_, err = parser.ParseSrc(fn)
+	return result
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("can't parse Rule '%s': %w", rule, err)
+// hasTransferType checks if AXFR or IXFR is one of the listed types of the rule
+func (r *Rule) hasTransferType() bool {
+	return slices.Contains(r.Types, dns.Type(dns.TypeAXFR)) || slices.Contains(r.Types, dns.Type(dns.TypeIXFR))
+}
+
+// MatchesType checks if the rule applies to the query type
+func (r *Rule) MatchesType(qType uint16) bool {
+	if r.Types == nil {
+		return true
+	}
+
+	for _, t := range r.Types {
+		if uint16(t) == qType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitRule splits "address [options]/function" into its parts, options are optional.
+// Only a trailing " [...]" block before the function is split off as options, so the
+// address may contain spaces and brackets, but no '/'
+func splitRule(in string) (address, options, fn string, err error) {
+	slash := strings.Index(in, "/")
+	if slash < 0 {
+		return "", "", "", errors.New("rule should contain '/'")
+	}
+
+	address, fn = in[:slash], in[slash+1:]
+
+	opening := strings.LastIndex(address, " [")
+	if opening < 0 {
+		return address, "", fn, nil
+	}
+
+	if !strings.Contains(address[opening:], "]") {
+		// the options block contains a '/', for example a network
+		closing := strings.Index(in[opening:], "]/")
+		if closing < 0 {
+			return "", "", "", errors.New("rule options should be closed with ']'")
+		}
+
+		return in[:opening], in[opening+2 : opening+closing], in[opening+closing+2:], nil
+	}
+
+	if strings.HasSuffix(address, "]") {
+		address, options = address[:opening], address[opening+2:len(address)-1]
+	}
+
+	return address, options, fn, nil
+}
+
+// applyOptions parses space separated "key=value" options and sets them on the rule,
+// returns the name matcher kind (regex if not defined)
+func applyOptions(rule *Rule, options string) (matchKind string, err error) {
+	matchKind = MatchRegex
+
+	for _, option := range strings.Fields(options) {
+		pair := strings.SplitN(option, "=", tupleSize)
+		if len(pair) != tupleSize {
+			return "", fmt.Errorf("option '%s' should be in format 'key=value'", option)
+		}
+
+		switch pair[0] {
+		case "match":
+			matchKind = pair[1]
+		case "client":
+			rule.Clients, err = parseNetworks(pair[1])
+		case "ecs":
+			rule.ECS, err = parseNetworks(pair[1])
+		case "view":
+			rule.Views = strings.Split(pair[1], ",")
+		case "opcode":
+			rule.Opcode, err = parseOpcode(pair[1])
+		case "tsig":
+			rule.TSIGKeys = strings.Split(pair[1], ",")
+		case "tsigerror":
+			rule.TSIGError, err = parseTSIGError(pair[1])
+		case "dnssec":
+			rule.DNSSEC, err = parseDNSSECMode(pair[1])
+		default:
+			return "", fmt.Errorf("unknown option '%s'", pair[0])
 		}
 
-		res, err := vm.Execute(env, nil, fn)
 		if err != nil {
-			return nil, fmt.Errorf("can't execute function: %w", err)
+			return "", err
 		}
+	}
+
+	return matchKind, nil
+}
 
-		result := res.(mock.Result)
-		if result.Err != nil {
-			return nil, fmt.Errorf("can't execute function: %w", result.Err)
+// parseNetworks parses a comma separated list of CIDRs or single IP addresses
+func parseNetworks(in string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, n := range strings.Split(in, ",") {
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, fmt.Errorf("can't parse IP address '%s'", n)
+			}
+
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+
+			continue
 		}
 
-		if rules[dns.Type(rType)] == nil {
-			rules[dns.Type(rType)] = make([]RegexRule, 0)
+		_, network, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse CIDR: %w", err)
 		}
 
-		rules[dns.Type(rType)] = append(rules[dns.Type(rType)], RegexRule{
-			Regex: regex,
-			Rule:  fn,
-		})
+		networks = append(networks, network)
 	}
 
-	return rules, nil
+	return networks, nil
+}
+
+// MatchesClient checks if the client address and the EDNS client subnet address (can be nil) are allowed by the rule
+func (r *Rule) MatchesClient(clientIP, ecsIP net.IP) bool {
+	if len(r.Clients) > 0 && !containsIP(r.Clients, clientIP) {
+		return false
+	}
+
+	if len(r.ECS) > 0 && !containsIP(r.ECS, ecsIP) {
+		return false
+	}
+
+	return true
+}
+
+// parseOpcode parses the name of an opcode, for example "update"
+func parseOpcode(in string) (int, error) {
+	opcode, found := dns.StringToOpcode[strings.ToUpper(in)]
+	if !found {
+		return 0, fmt.Errorf("unknown opcode '%s'", in)
+	}
+
+	return opcode, nil
+}
+
+// MatchesView checks if the rule applies to listeners of the view
+func (r *Rule) MatchesView(view string) bool {
+	if len(r.Views) == 0 {
+		return true
+	}
+
+	for _, v := range r.Views {
+		if v == view {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func retrieveRulesFromEnv() (ruleNames []string) {
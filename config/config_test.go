@@ -1,8 +1,12 @@
 package config
 
 import (
+	"net"
 	"os"
 
+	"github.com/0xERR0R/dns-mokka/dnssec"
+	"github.com/0xERR0R/dns-mokka/querylog"
+	"github.com/miekg/dns"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/sirupsen/logrus"
@@ -26,6 +30,92 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		When("CNAME following is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envFollowCNAME, "true")
+				os.Setenv(envCNAMEMaxDepth, "3")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should enable CNAME following", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.FollowCNAME).Should(BeTrue())
+				Expect(cfg.CNAMEMaxDepth).Should(Equal(3))
+			})
+		})
+
+		When("CNAME following flag is invalid", func() {
+			BeforeEach(func() {
+				os.Setenv(envFollowCNAME, "maybe")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("can't parse 'MOKKA_FOLLOW_CNAME'"))
+			})
+		})
+
+		When("multi question mode is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envMultiQuestion, MultiQuestionAll)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should use the mode", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.MultiQuestion).Should(Equal(MultiQuestionAll))
+			})
+		})
+
+		When("multi question mode is unknown", func() {
+			BeforeEach(func() {
+				os.Setenv(envMultiQuestion, "some")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown multi question mode 'some'"))
+			})
+		})
+
+		When("encrypted listeners are configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envDoTAddress, ":853")
+				os.Setenv(envDoHAddress, ":443")
+				os.Setenv(envDoQAddress, ":853")
+				os.Setenv(envTLSCertFile, "/tmp/cert.pem")
+				os.Setenv(envTLSKeyFile, "/tmp/key.pem")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should read the TLS config", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Listeners).Should(Equal([]Listener{
+					{Protocol: ProtocolUDP, Address: ":53"},
+					{Protocol: ProtocolTCP, Address: ":53"},
+					{Protocol: ProtocolDoT, Address: ":853"},
+					{Protocol: ProtocolDoH, Address: ":443"},
+					{Protocol: ProtocolDoQ, Address: ":853"},
+				}))
+				Expect(cfg.TLS.CertFile).Should(Equal("/tmp/cert.pem"))
+				Expect(cfg.TLS.KeyFile).Should(Equal("/tmp/key.pem"))
+			})
+		})
+
+		When("TLS key is missing", func() {
+			BeforeEach(func() {
+				os.Setenv(envTLSCertFile, "/tmp/cert.pem")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("both 'MOKKA_TLS_CERT_FILE' and 'MOKKA_TLS_KEY_FILE' must be defined"))
+			})
+		})
+
 		When("loglevel is invalid", func() {
 			BeforeEach(func() {
 				os.Setenv(envLogLevel, "invalid")
@@ -98,6 +188,18 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		When("zone file of transfer doesn't exist", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `AXFR example.com/TRANSFER("/does/not/exist.zone")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("can't load zone"))
+			})
+		})
+
 		When("query type is unknown", func() {
 			BeforeEach(func() {
 				os.Setenv(envRule+"1", `Unknown ./NOERROR("A 1.2.3.4 20")`)
@@ -122,6 +224,556 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		When("rule has client options", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A google [client=10.0.0.0/8,192.168.1.1 ecs=2001:db8::/32]/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the networks", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules).Should(HaveLen(1))
+
+				rule := cfg.Rules[0]
+				Expect(rule.Name).Should(Equal("1"))
+				Expect(rule.Rule).Should(Equal(`NOERROR("A 1.2.3.4 20")`))
+				Expect(rule.Clients).Should(HaveLen(2))
+				Expect(rule.ECS).Should(HaveLen(1))
+
+				Expect(rule.MatchesClient(net.ParseIP("10.1.2.3"), nil)).Should(BeFalse())
+				Expect(rule.MatchesClient(net.ParseIP("192.168.1.1"), net.ParseIP("2001:db8::1"))).Should(BeTrue())
+				Expect(rule.MatchesClient(net.ParseIP("192.168.1.2"), net.ParseIP("2001:db8::1"))).Should(BeFalse())
+			})
+		})
+
+		When("regex of rule contains spaces and brackets", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A ^(www|mail) ?[0-9]+\.example\.com/NOERROR("A 1.2.3.4 20")`)
+				os.Setenv(envRule+"2", `A ^[a-z]+ ?\.test [client=10.0.0.0/8]/NOERROR("A 1.2.3.5 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should keep the regex intact", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules).Should(HaveLen(2))
+
+				Expect(cfg.Rules[0].Rule).Should(Equal(`NOERROR("A 1.2.3.4 20")`))
+				Expect(cfg.Rules[0].Matcher.Match("www 1.example.com.")).Should(BeTrue())
+				Expect(cfg.Rules[0].Matcher.Match("www1.example.com.")).Should(BeTrue())
+				Expect(cfg.Rules[0].Matcher.Match("ftp1.example.com.")).Should(BeFalse())
+
+				Expect(cfg.Rules[1].Rule).Should(Equal(`NOERROR("A 1.2.3.5 20")`))
+				Expect(cfg.Rules[1].Clients).Should(HaveLen(1))
+				Expect(cfg.Rules[1].Matcher.Match("abc .test.")).Should(BeTrue())
+				Expect(cfg.Rules[1].Matcher.Match("1.test.")).Should(BeFalse())
+			})
+		})
+
+		When("rule has multiple types", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A,AAAA example.com/NOERROR("A 1.2.3.4 20", "AAAA ::1 20")`)
+				os.Setenv(envRule+"2", `* example.com/NOERROR("TXT test 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the types", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules).Should(HaveLen(2))
+				Expect(cfg.Rules[0].Types).Should(Equal([]dns.Type{dns.Type(dns.TypeA), dns.Type(dns.TypeAAAA)}))
+				Expect(cfg.Rules[0].MatchesType(dns.TypeAAAA)).Should(BeTrue())
+				Expect(cfg.Rules[0].MatchesType(dns.TypeMX)).Should(BeFalse())
+				Expect(cfg.Rules[1].Types).Should(BeNil())
+				Expect(cfg.Rules[1].MatchesType(dns.TypeMX)).Should(BeTrue())
+			})
+		})
+
+		When("type list contains unknown type", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A,Unknown example.com/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown type 'Unknown'"))
+			})
+		})
+
+		When("rule has match option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A example.com [match=exact]/NOERROR("A 1.2.3.4 20")`)
+				os.Setenv(envRule+"2", `A *.example.com [match=suffix]/NOERROR("A 1.2.3.5 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should use the selected matcher", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules).Should(HaveLen(2))
+
+				exact := cfg.Rules[0]
+				Expect(exact.Matcher.Match("example.com.")).Should(BeTrue())
+				Expect(exact.Matcher.Match("notexample.com.")).Should(BeFalse())
+
+				suffix := cfg.Rules[1]
+				Expect(suffix.Matcher.Match("www.example.com.")).Should(BeTrue())
+				Expect(suffix.Matcher.Match("example.com.")).Should(BeFalse())
+			})
+		})
+
+		When("rule has unknown match option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A example.com [match=fuzzy]/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown match kind 'fuzzy'"))
+			})
+		})
+
+		When("rule has view option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A example.com [view=primary,resolver]/NOERROR("A 1.2.3.4 20")`)
+				os.Setenv(envRule+"2", `A example.com/NOERROR("A 1.2.3.5 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should restrict the rule to the views", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules[0].Views).Should(Equal([]string{"primary", "resolver"}))
+				Expect(cfg.Rules[0].MatchesView("resolver")).Should(BeTrue())
+				Expect(cfg.Rules[0].MatchesView("secondary")).Should(BeFalse())
+				Expect(cfg.Rules[0].MatchesView("")).Should(BeFalse())
+				Expect(cfg.Rules[1].MatchesView("secondary")).Should(BeTrue())
+			})
+		})
+
+		When("rule has opcode option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `SOA example.com [opcode=update]/NOERROR()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should restrict the rule to the opcode", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules[0].Opcode).Should(Equal(dns.OpcodeUpdate))
+			})
+		})
+
+		When("rule has unknown opcode", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `SOA example.com [opcode=foo]/NOERROR()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown opcode 'foo'"))
+			})
+		})
+
+		When("admin API and NOTIFY targets are configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envAdminAddress, ":8080")
+				os.Setenv(envNotifyTargets, "10.0.0.1:5353, 10.0.0.2,[2001:db8::1]")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should use port 53 if not defined", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.AdminAddress).Should(Equal(":8080"))
+				Expect(cfg.NotifyTargets).Should(Equal([]string{"10.0.0.1:5353", "10.0.0.2:53", "[2001:db8::1]:53"}))
+			})
+		})
+
+		When("TSIG keys are configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envTSIGKeys, "Key1:hmac-sha256:c2VjcmV0, key2.:HMAC-SHA512:c2VjcmV0")
+				os.Setenv(envRule+"1", `SOA example.com [opcode=update tsig=key1 tsigerror=badtime]/NOERROR()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the keys and rule options", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.TSIGKeys).Should(Equal([]TSIGKey{
+					{Name: "key1.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"},
+					{Name: "key2.", Algorithm: dns.HmacSHA512, Secret: "c2VjcmV0"},
+				}))
+				Expect(cfg.Rules[0].TSIGKeys).Should(Equal([]string{"key1"}))
+				Expect(cfg.Rules[0].TSIGError).Should(Equal(TSIGBadTime))
+			})
+		})
+
+		When("TSIG key has unknown algorithm", func() {
+			BeforeEach(func() {
+				os.Setenv(envTSIGKeys, "key1:hmac-foo:c2VjcmV0")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown TSIG algorithm 'hmac-foo'"))
+			})
+		})
+
+		When("TSIG key has invalid format", func() {
+			BeforeEach(func() {
+				os.Setenv(envTSIGKeys, "key1:c2VjcmV0")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("should be in format 'name:algorithm:secret'"))
+			})
+		})
+
+		When("rule uses unknown TSIG key", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `SOA example.com [tsig=key1]/NOERROR()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown TSIG key 'key1'"))
+			})
+		})
+
+		When("rule has unknown TSIG error", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `SOA example.com [tsigerror=foo]/NOERROR()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown TSIG error 'foo'"))
+			})
+		})
+
+		When("DNSSEC is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNSSECZones, "Example.com, example.org.")
+				os.Setenv(envDNSSECAlgorithm, "ed25519")
+				os.Setenv(envDNSSECKeyDir, "/keys")
+				os.Setenv(envDNSSECDenial, "nsec3")
+				os.Setenv(envDNSSECWhiteLies, "true")
+				os.Setenv(envNSEC3Iterations, "5")
+				os.Setenv(envNSEC3Salt, "cafe")
+				os.Setenv(envNSEC3OptOut, "true")
+				os.Setenv(envRule+"1", `A www.example.com [dnssec=expired]/NOERROR("A 1.2.3.4 20")`)
+				os.Setenv(envRule+"2", `A,MX mail.example.com [match=exact]/NOERROR("MX 10 mx.example.com. 20", "TXT a 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the zones and the signature mode", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.DNSSEC).Should(Equal(DNSSECConfig{
+					Zones:     []string{"example.com.", "example.org."},
+					Algorithm: dns.ED25519,
+					KeyDir:    "/keys",
+					Denial: dnssec.Denial{
+						Mode: dnssec.DenialNSEC3, WhiteLies: true, Iterations: 5, Salt: "CAFE", OptOut: true,
+					},
+				}))
+				Expect(cfg.Rules[0].DNSSEC).Should(Equal("expired"))
+				Expect(cfg.Rules[0].RecordTypes).Should(Equal([]uint16{dns.TypeA}))
+				Expect(cfg.Rules[1].ExactName()).Should(Equal("mail.example.com."))
+				Expect(cfg.Rules[1].RecordTypes).Should(Equal([]uint16{dns.TypeMX}))
+			})
+		})
+
+		When("default responses are configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envDefaultResponse, "REFUSED()")
+				os.Setenv(envDefaultResponse+"_AAAA", "NOERROR()")
+				os.Setenv(envDefaultResponse+"_mx", `NOERROR("MX 10 mx.example.com. 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the default rules of the types followed by the default rule of all types", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Rules).Should(BeEmpty())
+				Expect(cfg.Defaults).Should(HaveLen(3))
+
+				Expect(cfg.Defaults[0].Name).Should(Equal("default_AAAA"))
+				Expect(cfg.Defaults[0].Types).Should(Equal([]dns.Type{dns.Type(dns.TypeAAAA)}))
+				Expect(cfg.Defaults[0].RecordTypes).Should(BeEmpty())
+
+				Expect(cfg.Defaults[1].Name).Should(Equal("default_mx"))
+				Expect(cfg.Defaults[1].RecordTypes).Should(Equal([]uint16{dns.TypeMX}))
+
+				Expect(cfg.Defaults[2].Name).Should(Equal("default"))
+				Expect(cfg.Defaults[2].Types).Should(BeNil())
+				Expect(cfg.Defaults[2].Rule).Should(Equal("REFUSED()"))
+				Expect(cfg.Defaults[2].Matcher.Match("any.example.com.")).Should(BeTrue())
+			})
+		})
+
+		When("upstream is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envUpstream, "192.0.2.53")
+				os.Setenv(envDefaultResponse+"_AAAA", "NOERROR()")
+				os.Setenv(envRule+"1", `A www.example.com/FORWARD("ttl=10")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should forward questions without matching rule to the upstream", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Upstream).Should(Equal("192.0.2.53:53"))
+				Expect(cfg.Rules[0].UsesUpstream).Should(BeTrue())
+				Expect(cfg.Rules[0].RecordTypes).Should(BeNil())
+				Expect(cfg.Defaults).Should(HaveLen(2))
+				Expect(cfg.Defaults[0].Name).Should(Equal("default_AAAA"))
+				Expect(cfg.Defaults[1].Name).Should(Equal("default"))
+				Expect(cfg.Defaults[1].Rule).Should(Equal("FORWARD()"))
+			})
+		})
+
+		When("upstream and default response are configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envUpstream, "192.0.2.53")
+				os.Setenv(envDefaultResponse, "REFUSED()")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should use the default response", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.Defaults).Should(HaveLen(1))
+				Expect(cfg.Defaults[0].Rule).Should(Equal("REFUSED()"))
+			})
+		})
+
+		When("rule forwards to the upstream, but upstream is not configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A www.example.com/FORWARD()`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("rule '1' forwards to the upstream, but MOKKA_UPSTREAM is not defined"))
+			})
+		})
+
+		DescribeTable("rule forwards zone transfers, updates or notifications",
+			func(rule string) {
+				os.Setenv(envRule+"1", rule)
+				DeferCleanup(os.Clearenv)
+
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("FORWARD() can't answer zone transfers, updates or notifications"))
+			},
+			Entry("update", `SOA example.com [opcode=update]/FORWARD("192.0.2.53")`),
+			Entry("notify", `SOA example.com [opcode=notify]/FORWARD("192.0.2.53")`),
+			Entry("zone transfer", `AXFR,IXFR example.com/FORWARD("192.0.2.53")`),
+		)
+
+		When("default response has an unknown type", func() {
+			BeforeEach(func() {
+				os.Setenv(envDefaultResponse+"_FOO", "REFUSED()")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown type 'FOO' of default response"))
+			})
+		})
+
+		When("default response has an invalid function", func() {
+			BeforeEach(func() {
+				os.Setenv(envDefaultResponse, "UNKNOWN()")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("can't parse default response 'UNKNOWN()'"))
+			})
+		})
+
+		When("query log is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envQueryLogTarget, "/tmp/queries.csv")
+				os.Setenv(envQueryLogFormat, "csv")
+				os.Setenv(envQueryLogMaxSize, "10")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the target, the format and the rotation", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.QueryLog).Should(Equal(querylog.Options{
+					Target: "/tmp/queries.csv", Format: querylog.FormatCSV, MaxSize: 10, MaxBackups: 3,
+				}))
+			})
+		})
+
+		When("query log format is unknown", func() {
+			BeforeEach(func() {
+				os.Setenv(envQueryLogFormat, "xml")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown query log format 'xml'"))
+			})
+		})
+
+		When("dnstap socket is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNStapTarget, "unix:/var/run/dnstap.sock")
+				os.Setenv(envDNStapMessages, "client")
+				os.Setenv(envDNStapIdentity, "mokka-1")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the socket and the message type", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.DNStap).Should(Equal(DNStapConfig{
+					Socket: "/var/run/dnstap.sock", Messages: DNStapClient, Identity: "mokka-1",
+				}))
+				Expect(cfg.DNStap.Enabled()).Should(BeTrue())
+			})
+		})
+
+		When("dnstap file is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNStapTarget, "/tmp/mokka.dnstap")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should write auth messages to the file", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.DNStap.File).Should(Equal("/tmp/mokka.dnstap"))
+				Expect(cfg.DNStap.Messages).Should(Equal(DNStapAuth))
+			})
+		})
+
+		When("pcap file is configured", func() {
+			BeforeEach(func() {
+				os.Setenv(envPcapFile, "/tmp/mokka.pcap")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should parse the file", func() {
+				cfg, err := ReadConfig()
+				Expect(err).Should(Succeed())
+				Expect(cfg.PcapFile).Should(Equal("/tmp/mokka.pcap"))
+			})
+		})
+
+		When("dnstap message type is unknown", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNStapMessages, "resolver")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown dnstap message type 'resolver'"))
+			})
+		})
+
+		When("DNSSEC algorithm is unknown", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNSSECAlgorithm, "foo")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown DNSSEC algorithm 'foo'"))
+			})
+		})
+
+		When("DNSSEC denial mode is unknown", func() {
+			BeforeEach(func() {
+				os.Setenv(envDNSSECDenial, "foo")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown DNSSEC denial mode 'foo'"))
+			})
+		})
+
+		When("NSEC3 salt is not hex encoded", func() {
+			BeforeEach(func() {
+				os.Setenv(envNSEC3Salt, "xyz")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("must be hex encoded"))
+			})
+		})
+
+		When("rule has unknown DNSSEC signature mode", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A example.com [dnssec=foo]/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown DNSSEC signature mode 'foo'"))
+			})
+		})
+
+		When("rule has invalid client option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A google [client=10.0.0.0/33]/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("can't parse CIDR"))
+			})
+		})
+
+		When("rule has unknown option", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A google [foo=bar]/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("unknown option 'foo'"))
+			})
+		})
+
+		When("rule has no space between types and name", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("rule should contain a space between types and name"))
+			})
+		})
+
+		When("rule options are not closed", func() {
+			BeforeEach(func() {
+				os.Setenv(envRule+"1", `A google [client=10.0.0.0/8/NOERROR("A 1.2.3.4 20")`)
+				DeferCleanup(os.Clearenv)
+			})
+			It("should fail", func() {
+				_, err := ReadConfig()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("rule options should be closed with ']'"))
+			})
+		})
+
 		When("Rule in wrong format", func() {
 			BeforeEach(func() {
 				os.Setenv(envRule+"1", `A NOERROR("A 1.2.3.4 20")`)
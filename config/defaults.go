@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mattn/anko/env"
+	"github.com/miekg/dns"
+)
+
+const (
+	// envDefaultResponse is the function for questions without matching rule, it can be suffixed with a type
+	// ("MOKKA_DEFAULT_RESPONSE_AAAA") to define the function for questions of this type
+	envDefaultResponse = prefix + "DEFAULT_RESPONSE"
+	// DefaultRuleName is the name of the default rule, per type default rules have the type as suffix ("default_AAAA")
+	DefaultRuleName = "default"
+	// upstreamFunction forwards the questions to the global upstream
+	upstreamFunction = "FORWARD()"
+)
+
+// retrieveDefaultRules returns the default rules of the types followed by the default rule of all types. If the
+// default rule of all types is not defined, but the upstream is, the questions are forwarded to the upstream.
+func retrieveDefaultRules(env *env.Env, upstream string) ([]Rule, error) {
+	var rules []Rule
+
+	for _, e := range os.Environ() {
+		pair := strings.SplitN(e, "=", tupleSize)
+
+		name, found := strings.CutPrefix(pair[0], envDefaultResponse)
+		if !found || (name != "" && !strings.HasPrefix(name, "_")) {
+			continue
+		}
+
+		r := Rule{
+			Name:    DefaultRuleName + name,
+			Matcher: &suffixMatcher{suffix: "."},
+			Rule:    strings.TrimSpace(pair[1]),
+		}
+
+		if name != "" {
+			rType, known := dns.StringToType[strings.ToUpper(name[1:])]
+			if !known {
+				return nil, fmt.Errorf("unknown type '%s' of default response", name[1:])
+			}
+
+			r.Types = []dns.Type{dns.Type(rType)}
+		}
+
+		if err := checkFunction(env, &r); err != nil {
+			return nil, fmt.Errorf("can't parse default response '%s': %w", r.Rule, err)
+		}
+
+		rules = append(rules, r)
+	}
+
+	// the default rule of all types is the last one
+	sort.Slice(rules, func(i, j int) bool {
+		if (rules[i].Types == nil) != (rules[j].Types == nil) {
+			return rules[j].Types == nil
+		}
+
+		return rules[i].Name < rules[j].Name
+	})
+
+	if upstream != "" && (len(rules) == 0 || rules[len(rules)-1].Types != nil) {
+		rules = append(rules, Rule{
+			Name:         DefaultRuleName,
+			Matcher:      &suffixMatcher{suffix: "."},
+			Rule:         upstreamFunction,
+			UsesUpstream: true,
+		})
+	}
+
+	return rules, nil
+}
+
+// validateUpstream checks that the global upstream is defined if a rule forwards to it
+func validateUpstream(c *Config) error {
+	if c.Upstream != "" {
+		return nil
+	}
+
+	for _, r := range slices.Concat(c.Rules, c.Defaults) {
+		if r.UsesUpstream {
+			return fmt.Errorf("rule '%s' forwards to the upstream, but %s is not defined", r.Name, envUpstream)
+		}
+	}
+
+	return nil
+}
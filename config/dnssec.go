@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/0xERR0R/dns-mokka/dnssec"
+	"github.com/miekg/dns"
+)
+
+const (
+	envDNSSECZones     = prefix + "DNSSEC_ZONES"
+	envDNSSECAlgorithm = prefix + "DNSSEC_ALGORITHM"
+	envDNSSECKeyDir    = prefix + "DNSSEC_KEY_DIR"
+	envDNSSECDenial    = prefix + "DNSSEC_DENIAL"
+	envDNSSECWhiteLies = prefix + "DNSSEC_WHITE_LIES"
+	envNSEC3Iterations = prefix + "DNSSEC_NSEC3_ITERATIONS"
+	envNSEC3Salt       = prefix + "DNSSEC_NSEC3_SALT"
+	envNSEC3OptOut     = prefix + "DNSSEC_NSEC3_OPT_OUT"
+	// maxSaltLength is the maximal length of the hex encoded salt (255 octets)
+	maxSaltLength = 510
+)
+
+// DNSSECConfig contains the zones whose answers are signed for requests with the DO bit
+type DNSSECConfig struct {
+	// Zones are the fully qualified lower case names of the signed zones, signing is disabled if empty
+	Zones []string
+	// Algorithm of the generated keys, default is ECDSAP256SHA256
+	Algorithm uint8
+	// KeyDir is the directory to read the keys from and to write the generated keys to (optional)
+	KeyDir string
+	// Denial defines the NSEC or NSEC3 records of negative answers
+	Denial dnssec.Denial
+}
+
+func retrieveDNSSECConfigFromEnv() (DNSSECConfig, error) {
+	c := DNSSECConfig{
+		Algorithm: dns.ECDSAP256SHA256,
+		KeyDir:    os.Getenv(envDNSSECKeyDir),
+	}
+
+	if v := os.Getenv(envDNSSECZones); v != "" {
+		for _, z := range strings.Split(v, ",") {
+			c.Zones = append(c.Zones, dns.CanonicalName(strings.TrimSpace(z)))
+		}
+	}
+
+	if v, found := os.LookupEnv(envDNSSECAlgorithm); found {
+		algorithm, known := dns.StringToAlgorithm[strings.ToUpper(v)]
+		if !known {
+			return DNSSECConfig{}, fmt.Errorf("unknown DNSSEC algorithm '%s'", v)
+		}
+
+		c.Algorithm = algorithm
+	}
+
+	var err error
+
+	c.Denial, err = retrieveDenialFromEnv()
+
+	return c, err
+}
+
+func retrieveDenialFromEnv() (d dnssec.Denial, err error) {
+	d.Mode = dnssec.DenialNSEC
+
+	if v, found := os.LookupEnv(envDNSSECDenial); found {
+		switch v {
+		case dnssec.DenialNSEC, dnssec.DenialNSEC3, dnssec.DenialCompact:
+			d.Mode = v
+		default:
+			return dnssec.Denial{}, fmt.Errorf("unknown DNSSEC denial mode '%s'", v)
+		}
+	}
+
+	if d.WhiteLies, err = retrieveBoolFromEnv(envDNSSECWhiteLies, false); err != nil {
+		return dnssec.Denial{}, err
+	}
+
+	if d.OptOut, err = retrieveBoolFromEnv(envNSEC3OptOut, false); err != nil {
+		return dnssec.Denial{}, err
+	}
+
+	iterations, err := retrieveIntFromEnv(envNSEC3Iterations, 0)
+	if err != nil {
+		return dnssec.Denial{}, err
+	}
+
+	if iterations < 0 || iterations > math.MaxUint16 {
+		return dnssec.Denial{}, fmt.Errorf("'%s' must be between 0 and %d", envNSEC3Iterations, math.MaxUint16)
+	}
+
+	d.Iterations = uint16(iterations)
+	d.Salt = strings.ToUpper(strings.TrimPrefix(os.Getenv(envNSEC3Salt), "-"))
+
+	if _, err := hex.DecodeString(d.Salt); err != nil || len(d.Salt) > maxSaltLength {
+		return dnssec.Denial{}, fmt.Errorf("'%s' must be hex encoded with at most %d characters", envNSEC3Salt,
+			maxSaltLength)
+	}
+
+	return d, nil
+}
+
+// parseDNSSECMode checks the value of the "dnssec" rule option
+func parseDNSSECMode(in string) (string, error) {
+	switch in {
+	case dnssec.SignatureExpired, dnssec.SignatureNotYetValid, dnssec.SignatureBogus, dnssec.SignatureNone:
+		return in, nil
+	}
+
+	return "", fmt.Errorf("unknown DNSSEC signature mode '%s'", in)
+}
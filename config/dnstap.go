@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	envDNStapTarget   = prefix + "DNSTAP_TARGET"
+	envDNStapMessages = prefix + "DNSTAP_MESSAGES"
+	envDNStapIdentity = prefix + "DNSTAP_IDENTITY"
+	// dnstapSocketPrefix marks a unix socket target, other targets are files
+	dnstapSocketPrefix = "unix:"
+)
+
+// dnstap message types
+const (
+	// DNStapAuth writes AUTH_QUERY and AUTH_RESPONSE messages like an authoritative server
+	DNStapAuth = "auth"
+	// DNStapClient writes CLIENT_QUERY and CLIENT_RESPONSE messages like a resolver
+	DNStapClient = "client"
+)
+
+// DNStapConfig defines the output of the dnstap messages
+type DNStapConfig struct {
+	// Socket is the path of the unix socket of the dnstap collector
+	Socket string
+	// File is the path of the file to write the dnstap frames to
+	File string
+	// Messages is the type of the messages, "auth" or "client"
+	Messages string
+	// Identity is the name of the server in the messages, default is the host name
+	Identity string
+}
+
+// Enabled checks if a socket or a file is configured
+func (c DNStapConfig) Enabled() bool {
+	return c.Socket != "" || c.File != ""
+}
+
+func retrieveDNStapConfigFromEnv() (DNStapConfig, error) {
+	c := DNStapConfig{
+		Messages: DNStapAuth,
+		Identity: os.Getenv(envDNStapIdentity),
+	}
+
+	if target := os.Getenv(envDNStapTarget); strings.HasPrefix(target, dnstapSocketPrefix) {
+		c.Socket = strings.TrimPrefix(target, dnstapSocketPrefix)
+	} else {
+		c.File = target
+	}
+
+	if v, found := os.LookupEnv(envDNStapMessages); found {
+		switch v {
+		case DNStapAuth, DNStapClient:
+			c.Messages = v
+		default:
+			return DNStapConfig{}, fmt.Errorf("unknown dnstap message type '%s'", v)
+		}
+	}
+
+	if c.Identity == "" {
+		c.Identity, _ = os.Hostname()
+	}
+
+	return c, nil
+}
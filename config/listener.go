@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listener protocols
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolDoT = "dot"
+	ProtocolDoH = "doh"
+	ProtocolDoQ = "doq"
+	// protocolDNS is a shortcut for UDP and TCP on the same address
+	protocolDNS = "dns"
+)
+
+const (
+	ipVersion4 = "4"
+	ipVersion6 = "6"
+)
+
+// Listener defines a transport and the address to bind to
+type Listener struct {
+	Protocol string
+	// IPVersion restricts the listener to IPv4 ("4") or IPv6 ("6"), empty for both
+	IPVersion string
+	Address   string
+	// View selects the rules with this view in addition to the rules without view
+	View string
+	// ProxyProtocol enables the PROXY protocol header (v1 or v2) on stream listeners
+	ProxyProtocol bool
+}
+
+// Network returns the network name for the socket, for example "tcp6"
+func (l Listener) Network() string {
+	if l.Protocol == ProtocolUDP || l.Protocol == ProtocolDoQ {
+		return "udp" + l.IPVersion
+	}
+
+	return "tcp" + l.IPVersion
+}
+
+// Encrypted returns true if the listener needs a certificate
+func (l Listener) Encrypted() bool {
+	return l.Protocol == ProtocolDoT || l.Protocol == ProtocolDoH || l.Protocol == ProtocolDoQ
+}
+
+func (l Listener) String() string {
+	query := url.Values{}
+
+	if l.View != "" {
+		query.Set("view", l.View)
+	}
+
+	if l.ProxyProtocol {
+		query.Set("proxy", "true")
+	}
+
+	if len(query) > 0 {
+		return fmt.Sprintf("%s%s://%s?%s", l.Protocol, l.IPVersion, l.Address, query.Encode())
+	}
+
+	return fmt.Sprintf("%s%s://%s", l.Protocol, l.IPVersion, l.Address)
+}
+
+// retrieveListenersFromEnv reads the listener list, falls back to the single address variables
+func retrieveListenersFromEnv() ([]Listener, error) {
+	var listeners []Listener
+
+	if list, found := os.LookupEnv(envListeners); found {
+		for _, l := range strings.Split(list, ",") {
+			parsed, err := parseListener(strings.TrimSpace(l))
+			if err != nil {
+				return nil, fmt.Errorf("can't parse listener '%s': %w", l, err)
+			}
+
+			listeners = append(listeners, parsed...)
+		}
+	} else {
+		address := ":53"
+		if addr, found := os.LookupEnv(envListenAddress); found {
+			address = addr
+		}
+
+		listeners = append(listeners,
+			Listener{Protocol: ProtocolUDP, Address: address},
+			Listener{Protocol: ProtocolTCP, Address: address})
+	}
+
+	for _, l := range []struct{ protocol, env string }{
+		{ProtocolDoT, envDoTAddress},
+		{ProtocolDoH, envDoHAddress},
+		{ProtocolDoQ, envDoQAddress},
+	} {
+		if addr := os.Getenv(l.env); addr != "" {
+			listeners = append(listeners, Listener{Protocol: l.protocol, Address: addr})
+		}
+	}
+
+	return listeners, nil
+}
+
+// parseListener parses a definition like "udp6://[::1]:53?view=primary",
+// the "dns" protocol creates an UDP and a TCP listener
+func parseListener(in string) ([]Listener, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("listener should be in format 'protocol://host:port'")
+	}
+
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return nil, err
+	}
+
+	protocol, ipVersion := u.Scheme, ""
+	if strings.HasSuffix(protocol, ipVersion4) || strings.HasSuffix(protocol, ipVersion6) {
+		protocol, ipVersion = protocol[:len(protocol)-1], protocol[len(protocol)-1:]
+	}
+
+	listener := Listener{Protocol: protocol, IPVersion: ipVersion, Address: u.Host}
+
+	for key, values := range u.Query() {
+		switch key {
+		case "view":
+			listener.View = values[0]
+		case "proxy":
+			if listener.ProxyProtocol, err = strconv.ParseBool(values[0]); err != nil {
+				return nil, fmt.Errorf("can't parse parameter 'proxy': %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown parameter '%s'", key)
+		}
+	}
+
+	switch protocol {
+	case ProtocolUDP, ProtocolDoQ:
+		if listener.ProxyProtocol {
+			return nil, fmt.Errorf("PROXY protocol is not supported for '%s'", protocol)
+		}
+
+		return []Listener{listener}, nil
+	case ProtocolTCP, ProtocolDoT, ProtocolDoH:
+		return []Listener{listener}, nil
+	case protocolDNS:
+		if listener.ProxyProtocol {
+			return nil, fmt.Errorf("PROXY protocol is not supported for '%s', use 'tcp'", protocol)
+		}
+
+		tcp := listener
+		listener.Protocol, tcp.Protocol = ProtocolUDP, ProtocolTCP
+
+		return []Listener{listener, tcp}, nil
+	}
+
+	return nil, fmt.Errorf("unknown protocol '%s'", u.Scheme)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener", func() {
+	Describe("parse listeners", func() {
+		When("listener list is defined", func() {
+			BeforeEach(func() {
+				os.Setenv(envListeners, "udp4://0.0.0.0:53, tcp6://[::]:53,dns://127.0.0.1:5353,dot://:853,doh6://[::1]:443,doq://:853")
+				os.Setenv(envListenAddress, ":54")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should create all listeners", func() {
+				listeners, err := retrieveListenersFromEnv()
+				Expect(err).Should(Succeed())
+				Expect(listeners).Should(Equal([]Listener{
+					{Protocol: ProtocolUDP, IPVersion: "4", Address: "0.0.0.0:53"},
+					{Protocol: ProtocolTCP, IPVersion: "6", Address: "[::]:53"},
+					{Protocol: ProtocolUDP, Address: "127.0.0.1:5353"},
+					{Protocol: ProtocolTCP, Address: "127.0.0.1:5353"},
+					{Protocol: ProtocolDoT, Address: ":853"},
+					{Protocol: ProtocolDoH, IPVersion: "6", Address: "[::1]:443"},
+					{Protocol: ProtocolDoQ, Address: ":853"},
+				}))
+			})
+		})
+
+		When("listener has a view", func() {
+			BeforeEach(func() {
+				os.Setenv(envListeners, "dns://:5353?view=secondary")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should set the view on all listeners", func() {
+				listeners, err := retrieveListenersFromEnv()
+				Expect(err).Should(Succeed())
+				Expect(listeners).Should(Equal([]Listener{
+					{Protocol: ProtocolUDP, Address: ":5353", View: "secondary"},
+					{Protocol: ProtocolTCP, Address: ":5353", View: "secondary"},
+				}))
+				Expect(listeners[0].String()).Should(Equal("udp://:5353?view=secondary"))
+			})
+		})
+
+		When("listener has PROXY protocol enabled", func() {
+			BeforeEach(func() {
+				os.Setenv(envListeners, "tcp://:53?proxy=true&view=internal,dot://:853?proxy=1")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should enable PROXY protocol", func() {
+				listeners, err := retrieveListenersFromEnv()
+				Expect(err).Should(Succeed())
+				Expect(listeners).Should(Equal([]Listener{
+					{Protocol: ProtocolTCP, Address: ":53", View: "internal", ProxyProtocol: true},
+					{Protocol: ProtocolDoT, Address: ":853", ProxyProtocol: true},
+				}))
+				Expect(listeners[0].String()).Should(Equal("tcp://:53?proxy=true&view=internal"))
+			})
+		})
+
+		When("only listen address is defined", func() {
+			BeforeEach(func() {
+				os.Setenv(envListenAddress, ":54")
+				os.Setenv(envDoTAddress, ":853")
+				DeferCleanup(os.Clearenv)
+			})
+			It("should create UDP, TCP and DoT listeners", func() {
+				listeners, err := retrieveListenersFromEnv()
+				Expect(err).Should(Succeed())
+				Expect(listeners).Should(Equal([]Listener{
+					{Protocol: ProtocolUDP, Address: ":54"},
+					{Protocol: ProtocolTCP, Address: ":54"},
+					{Protocol: ProtocolDoT, Address: ":853"},
+				}))
+			})
+		})
+
+		DescribeTable("invalid listeners",
+			func(definition, expectedError string) {
+				os.Setenv(envListeners, definition)
+				DeferCleanup(os.Clearenv)
+
+				_, err := retrieveListenersFromEnv()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring(expectedError))
+			},
+			Entry("unknown protocol", "sctp://:53", "unknown protocol 'sctp'"),
+			Entry("missing port", "udp://127.0.0.1", "missing port in address"),
+			Entry("missing protocol", ":53", "missing protocol scheme"),
+			Entry("path", "doh://:443/dns-query", "listener should be in format 'protocol://host:port'"),
+			Entry("unknown parameter", "udp://:53?foo=bar", "unknown parameter 'foo'"),
+			Entry("invalid proxy parameter", "tcp://:53?proxy=maybe", "can't parse parameter 'proxy'"),
+			Entry("proxy on UDP", "udp://:53?proxy=true", "PROXY protocol is not supported for 'udp'"),
+			Entry("proxy on UDP and TCP", "dns://:53?proxy=true", "PROXY protocol is not supported for 'dns', use 'tcp'"),
+		)
+	})
+
+	Describe("network", func() {
+		DescribeTable("network of the listener",
+			func(l Listener, network string, encrypted bool) {
+				Expect(l.Network()).Should(Equal(network))
+				Expect(l.Encrypted()).Should(Equal(encrypted))
+			},
+			Entry("UDP", Listener{Protocol: ProtocolUDP}, "udp", false),
+			Entry("TCP IPv6", Listener{Protocol: ProtocolTCP, IPVersion: "6"}, "tcp6", false),
+			Entry("DoT IPv4", Listener{Protocol: ProtocolDoT, IPVersion: "4"}, "tcp4", true),
+			Entry("DoH", Listener{Protocol: ProtocolDoH}, "tcp", true),
+			Entry("DoQ", Listener{Protocol: ProtocolDoQ}, "udp", true),
+		)
+	})
+})
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// name matcher kinds, can be selected with the "match" rule option
+const (
+	MatchRegex  = "regex"
+	MatchExact  = "exact"
+	MatchSuffix = "suffix"
+	MatchGlob   = "glob"
+)
+
+const wildcardPrefix = "*."
+
+// NameMatcher checks if the domain name of a question matches a rule
+type NameMatcher interface {
+	Match(name string) bool
+}
+
+// NewMatcher creates a name matcher of the given kind for the pattern
+func NewMatcher(kind, pattern string) (NameMatcher, error) {
+	switch kind {
+	case MatchRegex:
+		regex, err := regexp.Compile(strings.ToLower(pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		return &regexMatcher{regex: regex}, nil
+	case MatchExact:
+		return &exactMatcher{name: normalizeName(pattern)}, nil
+	case MatchSuffix:
+		pattern = normalizeName(pattern)
+
+		return &suffixMatcher{
+			suffix:        dns.Fqdn(strings.TrimPrefix(pattern, wildcardPrefix)),
+			subdomainOnly: strings.HasPrefix(pattern, wildcardPrefix),
+		}, nil
+	case MatchGlob:
+		pattern = normalizeName(pattern)
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+
+		return &globMatcher{pattern: pattern}, nil
+	}
+
+	return nil, fmt.Errorf("unknown match kind '%s'", kind)
+}
+
+// normalizeName returns the lower case fully qualified domain name
+func normalizeName(name string) string {
+	return dns.Fqdn(strings.ToLower(name))
+}
+
+// regexMatcher matches the lower case name against a regular expression
+type regexMatcher struct {
+	regex *regexp.Regexp
+}
+
+func (m *regexMatcher) Match(name string) bool {
+	return m.regex.MatchString(strings.ToLower(name))
+}
+
+// exactMatcher matches exactly one domain name
+type exactMatcher struct {
+	name string
+}
+
+func (m *exactMatcher) Match(name string) bool {
+	return normalizeName(name) == m.name
+}
+
+// ExactName returns the name of a rule with exact matcher or empty string for other matchers
+func (r *Rule) ExactName() string {
+	if m, ok := r.Matcher.(*exactMatcher); ok {
+		return m.name
+	}
+
+	return ""
+}
+
+// suffixMatcher matches the domain and all subdomains ("example.com.")
+// or only the subdomains ("*.example.com.")
+type suffixMatcher struct {
+	suffix        string
+	subdomainOnly bool
+}
+
+func (m *suffixMatcher) Match(name string) bool {
+	name = normalizeName(name)
+
+	if name == m.suffix {
+		return !m.subdomainOnly
+	}
+
+	return dns.IsSubDomain(m.suffix, name)
+}
+
+// globMatcher matches the name against a shell pattern, for example "api-*.example.??."
+type globMatcher struct {
+	pattern string
+}
+
+func (m *globMatcher) Match(name string) bool {
+	matched, _ := path.Match(m.pattern, normalizeName(name))
+
+	return matched
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Matcher", func() {
+	DescribeTable("matching names",
+		func(kind, pattern, name string, expected bool) {
+			m, err := NewMatcher(kind, pattern)
+			Expect(err).Should(Succeed())
+			Expect(m.Match(name)).Should(Equal(expected))
+		},
+		Entry("regex matches substring", MatchRegex, "google", "notgoogle.evil.com.", true),
+		Entry("regex ignores case", MatchRegex, "google", "GOOGLE.com.", true),
+		Entry("exact matches same name", MatchExact, "example.com.", "Example.COM.", true),
+		Entry("exact matches without trailing dot", MatchExact, "example.com", "example.com.", true),
+		Entry("exact doesn't match subdomain", MatchExact, "example.com.", "www.example.com.", false),
+		Entry("suffix matches apex", MatchSuffix, "example.com.", "example.com.", true),
+		Entry("suffix matches subdomain", MatchSuffix, "example.com.", "a.b.example.com.", true),
+		Entry("suffix doesn't match other domain", MatchSuffix, "example.com.", "notexample.com.", false),
+		Entry("wildcard suffix doesn't match apex", MatchSuffix, "*.example.com.", "example.com.", false),
+		Entry("wildcard suffix matches subdomain", MatchSuffix, "*.example.com.", "www.example.com.", true),
+		Entry("glob matches pattern", MatchGlob, "api-*.example.??.", "api-eu.example.de.", true),
+		Entry("glob doesn't match other pattern", MatchGlob, "api-*.example.??.", "api-eu.example.com.", false),
+	)
+
+	DescribeTable("invalid patterns",
+		func(kind, pattern, expectedError string) {
+			_, err := NewMatcher(kind, pattern)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring(expectedError))
+		},
+		Entry("invalid regex", MatchRegex, ".[", "missing closing ]"),
+		Entry("invalid glob", MatchGlob, "[a", "syntax error in pattern"),
+		Entry("unknown kind", "fuzzy", "example.com", "unknown match kind 'fuzzy'"),
+	)
+})
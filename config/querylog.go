@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/0xERR0R/dns-mokka/querylog"
+)
+
+const (
+	envQueryLogTarget     = prefix + "QUERY_LOG_TARGET"
+	envQueryLogFormat     = prefix + "QUERY_LOG_FORMAT"
+	envQueryLogMaxSize    = prefix + "QUERY_LOG_MAX_SIZE"
+	envQueryLogMaxBackups = prefix + "QUERY_LOG_MAX_BACKUPS"
+	// defaultQueryLogMaxSize is the size of a query log file in megabytes before it is rotated
+	defaultQueryLogMaxSize    = 100
+	defaultQueryLogMaxBackups = 3
+)
+
+func retrieveQueryLogFromEnv() (o querylog.Options, err error) {
+	o = querylog.Options{
+		Target: os.Getenv(envQueryLogTarget),
+		Format: querylog.FormatJSON,
+	}
+
+	if v, found := os.LookupEnv(envQueryLogFormat); found {
+		switch v {
+		case querylog.FormatJSON, querylog.FormatCSV:
+			o.Format = v
+		default:
+			return querylog.Options{}, fmt.Errorf("unknown query log format '%s'", v)
+		}
+	}
+
+	if o.MaxSize, err = retrieveIntFromEnv(envQueryLogMaxSize, defaultQueryLogMaxSize); err != nil {
+		return querylog.Options{}, err
+	}
+
+	if o.MaxBackups, err = retrieveIntFromEnv(envQueryLogMaxBackups, defaultQueryLogMaxBackups); err != nil {
+		return querylog.Options{}, err
+	}
+
+	return o, nil
+}
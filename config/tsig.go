@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	envTSIGKeys   = prefix + "TSIG_KEYS"
+	tsigKeyFields = 3
+	// AnyTSIGKey in the "tsig" rule option matches requests signed with any configured key
+	AnyTSIGKey = "*"
+)
+
+// deliberate TSIG errors of the "tsigerror" rule option
+const (
+	// TSIGBadSig answers with NOTAUTH and TSIG error BADSIG
+	TSIGBadSig = "badsig"
+	// TSIGBadKey answers with NOTAUTH and TSIG error BADKEY
+	TSIGBadKey = "badkey"
+	// TSIGBadTime answers with NOTAUTH and TSIG error BADTIME
+	TSIGBadTime = "badtime"
+	// TSIGBadMAC answers normally, but signs the response with a wrong MAC
+	TSIGBadMAC = "badmac"
+)
+
+// TSIGKey is a shared secret to verify and sign messages (RFC 8945)
+type TSIGKey struct {
+	// Name is the fully qualified lower case key name
+	Name string
+	// Algorithm is the fully qualified algorithm name, for example "hmac-sha256."
+	Algorithm string
+	// Secret is the base64 encoded secret
+	Secret string
+}
+
+// retrieveTSIGKeysFromEnv parses the comma separated list of keys in format "name:algorithm:secret"
+func retrieveTSIGKeysFromEnv() ([]TSIGKey, error) {
+	v := os.Getenv(envTSIGKeys)
+	if v == "" {
+		return nil, nil
+	}
+
+	var keys []TSIGKey
+
+	for _, k := range strings.Split(v, ",") {
+		key, err := parseTSIGKey(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("can't parse '%s': %w", envTSIGKeys, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func parseTSIGKey(in string) (TSIGKey, error) {
+	parts := strings.SplitN(in, ":", tsigKeyFields)
+	if len(parts) != tsigKeyFields {
+		return TSIGKey{}, fmt.Errorf("key '%s' should be in format 'name:algorithm:secret'", in)
+	}
+
+	algorithm := dns.CanonicalName(parts[1])
+
+	switch algorithm {
+	case dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512:
+	default:
+		return TSIGKey{}, fmt.Errorf("unknown TSIG algorithm '%s'", parts[1])
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return TSIGKey{}, fmt.Errorf("secret of key '%s' is not base64 encoded: %w", parts[0], err)
+	}
+
+	return TSIGKey{
+		Name:      dns.CanonicalName(parts[0]),
+		Algorithm: algorithm,
+		Secret:    parts[2],
+	}, nil
+}
+
+// validateTSIGKeys checks that the keys in the "tsig" option of the rules are configured
+func validateTSIGKeys(c *Config) error {
+	known := map[string]bool{AnyTSIGKey: true}
+
+	for _, k := range c.TSIGKeys {
+		known[k.Name] = true
+	}
+
+	for _, r := range c.Rules {
+		for _, k := range r.TSIGKeys {
+			if !known[k] && !known[dns.CanonicalName(k)] {
+				return fmt.Errorf("rule '%s' uses unknown TSIG key '%s'", r.Name, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseTSIGError checks the value of the "tsigerror" rule option
+func parseTSIGError(in string) (string, error) {
+	switch in {
+	case TSIGBadSig, TSIGBadKey, TSIGBadTime, TSIGBadMAC:
+		return in, nil
+	}
+
+	return "", fmt.Errorf("unknown TSIG error '%s'", in)
+}
+
+// MatchesTSIG checks if the request was signed with one of the keys of the rule, keyName is the name
+// of the verified key or empty if the request is not signed
+func (r *Rule) MatchesTSIG(keyName string) bool {
+	if len(r.TSIGKeys) == 0 {
+		return true
+	}
+
+	if keyName == "" {
+		return false
+	}
+
+	for _, k := range r.TSIGKeys {
+		if k == AnyTSIGKey || dns.CanonicalName(k) == keyName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,469 @@
+package dnssec
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// denial of existence modes
+const (
+	// DenialNSEC proves negative answers with NSEC records (RFC 4034)
+	DenialNSEC = "nsec"
+	// DenialNSEC3 proves negative answers with hashed NSEC3 records (RFC 5155)
+	DenialNSEC3 = "nsec3"
+	// DenialCompact answers NXDOMAIN with NOERROR and one NSEC record with the NXNAME type (RFC 9824)
+	DenialCompact = "compact"
+)
+
+const (
+	nsec3HashLength = 20
+	nsec3OptOut     = 1
+	// epsilonLabel is the first label of the immediate successor of a name in the canonical order
+	epsilonLabel  = "\\000."
+	maxNameLength = 255
+	maxOctet      = 0xFF
+)
+
+// Denial creates the NSEC or NSEC3 records which prove that a name or a type doesn't exist
+type Denial struct {
+	// Mode is DenialNSEC, DenialNSEC3 or DenialCompact
+	Mode string
+	// WhiteLies creates minimally covering records for each response instead of records of the chain
+	// of all names, so the zone can't be walked (RFC 4470)
+	WhiteLies bool
+	// Iterations are the additional NSEC3 hash iterations
+	Iterations uint16
+	// Salt is the hex encoded NSEC3 salt, empty for no salt
+	Salt string
+	// OptOut sets the NSEC3 opt-out flag and omits insecure delegations from the chain
+	OptOut bool
+}
+
+// Names contains the record types of the names of a zone, empty non-terminals have no types
+type Names map[string][]uint16
+
+// Add adds the name with the types and its ancestors up to the zone apex as empty non-terminals
+func (n Names) Add(zone, name string, types ...uint16) {
+	zone, name = dns.CanonicalName(zone), dns.CanonicalName(name)
+
+	existing, ok := n[name]
+	if !ok {
+		existing = []uint16{}
+	}
+
+	for _, t := range types {
+		if !slices.Contains(existing, t) {
+			existing = append(existing, t)
+		}
+	}
+
+	n[name] = existing
+
+	for p := name; p != zone && p != "."; {
+		p = parentName(p)
+
+		if _, ok := n[p]; !ok {
+			n[p] = []uint16{}
+		}
+	}
+}
+
+// NSEC3PARAM returns the NSEC3 parameters of the zone
+func (d Denial) NSEC3PARAM(zone string) *dns.NSEC3PARAM {
+	return &dns.NSEC3PARAM{
+		Hdr:        dns.RR_Header{Name: dns.CanonicalName(zone), Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET},
+		Hash:       dns.SHA1,
+		Iterations: d.Iterations,
+		SaltLength: saltLength(d.Salt),
+		Salt:       d.Salt,
+	}
+}
+
+// Deny returns the NSEC or NSEC3 records which prove the negative answer (NXDOMAIN or NODATA) for the name
+// in the zone and the response code, compact denial answers NXDOMAIN with NOERROR.
+// The names must contain the apex and ttl is the negative TTL of the zone.
+func (d Denial) Deny(zone string, names Names, name string, rCode int, ttl uint32) ([]dns.RR, int) {
+	p := &proof{
+		Denial: d,
+		zone:   dns.CanonicalName(zone),
+		names:  names,
+		ttl:    ttl,
+	}
+
+	name = dns.CanonicalName(name)
+
+	switch d.Mode {
+	case DenialCompact:
+		return p.compact(name, rCode)
+	case DenialNSEC3:
+		return p.nsec3(name, rCode), rCode
+	}
+
+	return p.nsec(name, rCode), rCode
+}
+
+// proof creates the records of a negative answer
+type proof struct {
+	Denial
+	zone  string
+	names Names
+	ttl   uint32
+}
+
+func (p *proof) exists(name string) bool {
+	_, ok := p.names[name]
+
+	return ok
+}
+
+// closestEncloser returns the longest existing ancestor of the name (RFC 5155, 7.2.1)
+func (p *proof) closestEncloser(name string) string {
+	ce := parentName(name)
+
+	for ce != p.zone && ce != "." && !p.exists(ce) {
+		ce = parentName(ce)
+	}
+
+	return ce
+}
+
+// compact creates the NSEC record of compact denial (RFC 9824), the name always exists but has no types
+// (NXNAME for not existing names)
+func (p *proof) compact(name string, rCode int) ([]dns.RR, int) {
+	types := p.names[name]
+
+	if rCode == dns.RcodeNameError {
+		types = []uint16{dns.TypeNXNAME}
+	}
+
+	return []dns.RR{p.newNSEC(name, epsilonLabel+name, types)}, dns.RcodeSuccess
+}
+
+// nsec creates the NSEC records for NODATA (matching record) or NXDOMAIN (covering records of the name
+// and the wildcard at the closest encloser)
+func (p *proof) nsec(name string, rCode int) []dns.RR {
+	chain := p.nsecChain()
+
+	if rCode != dns.RcodeNameError {
+		types, ok := p.names[name]
+
+		if ok && len(types) == 0 && !p.WhiteLies {
+			// empty non-terminal, the next name of the covering record is a descendant
+			if nsec := p.coveringNSEC(chain, name, false); dns.IsSubDomain(name, nsec.NextDomain) {
+				return []dns.RR{nsec}
+			}
+		}
+
+		if len(types) == 0 {
+			// the name has no records, but a rule answered NODATA
+			return []dns.RR{p.newNSEC(name, epsilonLabel+name, nil)}
+		}
+
+		return []dns.RR{p.matchingNSEC(chain, name)}
+	}
+
+	wildcard := "*." + p.closestEncloser(name)
+
+	return unique(
+		p.coveringNSEC(chain, name, p.exists(name)),
+		p.coveringNSEC(chain, wildcard, p.exists(wildcard)),
+	)
+}
+
+// nsecChain returns the names with records in canonical order, the apex is the first name
+func (p *proof) nsecChain() []string {
+	chain := make([]string, 0, len(p.names))
+
+	for n, types := range p.names {
+		if len(types) > 0 || n == p.zone {
+			chain = append(chain, n)
+		}
+	}
+
+	sort.Slice(chain, func(i, j int) bool {
+		return compareNames(chain[i], chain[j]) < 0
+	})
+
+	return chain
+}
+
+func (p *proof) matchingNSEC(chain []string, name string) *dns.NSEC {
+	i := sort.Search(len(chain), func(i int) bool { return compareNames(chain[i], name) >= 0 })
+
+	next := epsilonLabel + name
+	if !p.WhiteLies {
+		next = chain[(i+1)%len(chain)]
+	}
+
+	return p.newNSEC(name, next, p.names[name])
+}
+
+// coveringNSEC returns the NSEC record of the previous name in the chain, which covers the name.
+// A white lie from the predecessor to the successor of the name is created if enabled or if the name
+// exists (a rule answered NXDOMAIN for a known name).
+func (p *proof) coveringNSEC(chain []string, name string, exists bool) *dns.NSEC {
+	if p.WhiteLies || exists {
+		return p.newNSEC(predecessor(name), epsilonLabel+name, nil)
+	}
+
+	i := sort.Search(len(chain), func(i int) bool { return compareNames(chain[i], name) >= 0 })
+	prev := (i - 1 + len(chain)) % len(chain)
+
+	return p.newNSEC(chain[prev], chain[(prev+1)%len(chain)], p.names[chain[prev]])
+}
+
+// predecessor returns a name which is shortly before the name in the canonical order (RFC 4471, 3.3.2):
+// the last octet of the first label is decremented and a maximal octet is appended
+func predecessor(name string) string {
+	labels := wireLabels(name)
+	if len(labels) == 0 {
+		return name
+	}
+
+	first, parent := labels[0], parentName(dns.CanonicalName(name))
+
+	switch {
+	case len(first) == 1 && first[0] == 0:
+		return parent
+	case first[len(first)-1] == 0:
+		first = first[:len(first)-1]
+	default:
+		first[len(first)-1]--
+		first = append(first, maxOctet)
+	}
+
+	return escapeLabel(first) + "." + strings.TrimPrefix(parent, ".")
+}
+
+// escapeLabel returns the presentation format of the label, octets other than letters, digits and hyphens
+// are escaped
+func escapeLabel(label []byte) string {
+	var sb strings.Builder
+
+	for _, b := range label {
+		if (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "\\%03d", b)
+		}
+	}
+
+	return sb.String()
+}
+
+func (p *proof) newNSEC(owner, next string, types []uint16) *dns.NSEC {
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: p.ttl},
+		NextDomain: next,
+		TypeBitMap: typeBitMap(types, dns.TypeRRSIG, dns.TypeNSEC),
+	}
+}
+
+// nsec3 creates the NSEC3 records for NODATA (matching record) or NXDOMAIN (closest encloser proof and
+// covering record of the wildcard, RFC 5155, 7.2.2)
+func (p *proof) nsec3(name string, rCode int) []dns.RR {
+	chain := p.nsec3Chain()
+
+	if rCode != dns.RcodeNameError {
+		return []dns.RR{p.matchingNSEC3(chain, name)}
+	}
+
+	ce := p.closestEncloser(name)
+	nextCloser := name
+
+	for dns.CountLabel(nextCloser) > dns.CountLabel(ce)+1 {
+		nextCloser = parentName(nextCloser)
+	}
+
+	wildcard := "*." + ce
+
+	return unique(
+		p.matchingNSEC3(chain, ce),
+		p.coveringNSEC3(chain, nextCloser, p.exists(nextCloser)),
+		p.coveringNSEC3(chain, wildcard, p.exists(wildcard)),
+	)
+}
+
+// hashedName is a name of the NSEC3 chain
+type hashedName struct {
+	hash  string
+	types []uint16
+}
+
+// nsec3Chain returns the hashed names in hash order, opt-out omits insecure delegations
+func (p *proof) nsec3Chain() []hashedName {
+	chain := make([]hashedName, 0, len(p.names))
+
+	for n, types := range p.names {
+		if p.OptOut && n != p.zone && slices.Contains(types, dns.TypeNS) && !slices.Contains(types, dns.TypeDS) {
+			continue
+		}
+
+		chain = append(chain, hashedName{hash: p.hash(n), types: types})
+	}
+
+	sort.Slice(chain, func(i, j int) bool {
+		return chain[i].hash < chain[j].hash
+	})
+
+	return chain
+}
+
+func (p *proof) hash(name string) string {
+	return dns.HashName(name, dns.SHA1, p.Iterations, p.Salt)
+}
+
+// matchingNSEC3 returns the NSEC3 record of the name, a name without types is created if the name
+// is unknown, but a rule answered NODATA
+func (p *proof) matchingNSEC3(chain []hashedName, name string) dns.RR {
+	h := p.hash(name)
+	i := sort.Search(len(chain), func(i int) bool { return chain[i].hash >= h })
+
+	if i == len(chain) || chain[i].hash != h {
+		return p.newNSEC3(h, incrementHash(h, 1), nil)
+	}
+
+	next := incrementHash(h, 1)
+	if !p.WhiteLies {
+		next = chain[(i+1)%len(chain)].hash
+	}
+
+	return p.newNSEC3(h, next, chain[i].types)
+}
+
+// coveringNSEC3 returns the NSEC3 record which covers the hash of the name. A white lie covers only the hash
+// of the name, it is created if enabled or if the name exists (a rule answered NXDOMAIN for a known name).
+func (p *proof) coveringNSEC3(chain []hashedName, name string, exists bool) dns.RR {
+	h := p.hash(name)
+
+	if p.WhiteLies || exists {
+		return p.newNSEC3(incrementHash(h, -1), incrementHash(h, 1), nil)
+	}
+
+	i := sort.Search(len(chain), func(i int) bool { return chain[i].hash >= h })
+	prev := (i - 1 + len(chain)) % len(chain)
+
+	return p.newNSEC3(chain[prev].hash, chain[(prev+1)%len(chain)].hash, chain[prev].types)
+}
+
+func (p *proof) newNSEC3(hash, next string, types []uint16) dns.RR {
+	var flags uint8
+	if p.OptOut {
+		flags = nsec3OptOut
+	}
+
+	var bitmap []uint16
+	if len(types) > 0 {
+		bitmap = typeBitMap(types, dns.TypeRRSIG)
+	}
+
+	return &dns.NSEC3{
+		Hdr: dns.RR_Header{
+			Name: strings.ToLower(hash) + "." + p.zone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: p.ttl,
+		},
+		Hash:       dns.SHA1,
+		Flags:      flags,
+		Iterations: p.Iterations,
+		SaltLength: saltLength(p.Salt),
+		Salt:       p.Salt,
+		HashLength: nsec3HashLength,
+		NextDomain: next,
+		TypeBitMap: bitmap,
+	}
+}
+
+// saltLength returns the length of the hex encoded salt in bytes
+func saltLength(salt string) uint8 {
+	return uint8(hex.DecodedLen(len(salt)))
+}
+
+// incrementHash adds delta (1 or -1) to the base32hex encoded hash, the result wraps around
+func incrementHash(hash string, delta int) string {
+	encoding := base32.HexEncoding.WithPadding(base32.NoPadding)
+
+	raw, err := encoding.DecodeString(hash)
+	if err != nil {
+		return hash
+	}
+
+	for i := len(raw) - 1; i >= 0; i-- {
+		before := raw[i]
+		raw[i] = byte(int(raw[i]) + delta)
+
+		// stop if there is no carry or borrow
+		if (delta > 0 && raw[i] > before) || (delta < 0 && raw[i] < before) {
+			break
+		}
+	}
+
+	return encoding.EncodeToString(raw)
+}
+
+// typeBitMap returns the sorted types without duplicates
+func typeBitMap(types []uint16, additional ...uint16) []uint16 {
+	result := make([]uint16, 0, len(types)+len(additional))
+
+	for _, t := range append(append([]uint16{}, types...), additional...) {
+		if !slices.Contains(result, t) {
+			result = append(result, t)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result
+}
+
+// unique removes records with the same owner name
+func unique(records ...dns.RR) []dns.RR {
+	result := make([]dns.RR, 0, len(records))
+	owners := make(map[string]bool, len(records))
+
+	for _, rr := range records {
+		if !owners[rr.Header().Name] {
+			owners[rr.Header().Name] = true
+
+			result = append(result, rr)
+		}
+	}
+
+	return result
+}
+
+// compareNames compares the names in the canonical DNS name order (RFC 4034, 6.1)
+func compareNames(a, b string) int {
+	la, lb := wireLabels(a), wireLabels(b)
+
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := bytes.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(la) - len(lb)
+}
+
+// wireLabels returns the lower case labels of the name in wire format, escaped characters are decoded
+func wireLabels(name string) [][]byte {
+	buf := make([]byte, maxNameLength)
+
+	end, err := dns.PackDomainName(dns.CanonicalName(name), buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+
+	var labels [][]byte
+
+	for off := 0; off < end && buf[off] != 0; off += int(buf[off]) + 1 {
+		labels = append(labels, bytes.ToLower(buf[off+1:off+1+int(buf[off])]))
+	}
+
+	return labels
+}
@@ -0,0 +1,213 @@
+package dnssec
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const zoneName = "example.test."
+
+// covers checks if the NSEC record covers the name in the canonical order
+func covers(rr dns.RR, name string) bool {
+	nsec := rr.(*dns.NSEC)
+
+	return compareNames(nsec.Hdr.Name, name) < 0 && compareNames(name, nsec.NextDomain) < 0
+}
+
+// nsec3Covers checks if one of the NSEC3 records covers the name, the owner itself is not covered
+func nsec3Covers(records []dns.RR, name string) bool {
+	for _, rr := range records {
+		if rr.(*dns.NSEC3).Cover(name) && !rr.(*dns.NSEC3).Match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nsec3Matches(records []dns.RR, name string) bool {
+	for _, rr := range records {
+		if rr.(*dns.NSEC3).Match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var _ = Describe("Denial", func() {
+	var names Names
+
+	BeforeEach(func() {
+		names = Names{}
+		names.Add(zoneName, zoneName, dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY)
+		names.Add(zoneName, "www.example.test.", dns.TypeA)
+		names.Add(zoneName, "a.b.example.test.", dns.TypeA)
+		names.Add(zoneName, "sub.example.test.", dns.TypeNS)
+	})
+
+	Describe("Names", func() {
+		It("should add empty non-terminals", func() {
+			Expect(names).Should(HaveKeyWithValue("b.example.test.", BeEmpty()))
+			Expect(names).Should(HaveKeyWithValue(zoneName, []uint16{dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY}))
+		})
+	})
+
+	Describe("compareNames", func() {
+		It("should use the canonical order", func() {
+			ordered := []string{
+				"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.",
+				"z.example.", "\\001.z.example.", "*.z.example.", "\\200.z.example.",
+			}
+
+			for i := 1; i < len(ordered); i++ {
+				Expect(compareNames(ordered[i-1], ordered[i])).Should(BeNumerically("<", 0), ordered[i])
+			}
+		})
+	})
+
+	Describe("NSEC", func() {
+		sut := Denial{Mode: DenialNSEC}
+
+		It("should prove NODATA with the matching record", func() {
+			records, rCode := sut.Deny(zoneName, names, "WWW.example.test.", dns.RcodeSuccess, 60)
+
+			Expect(rCode).Should(Equal(dns.RcodeSuccess))
+			Expect(records).Should(HaveLen(1))
+
+			nsec := records[0].(*dns.NSEC)
+			Expect(nsec.Hdr.Name).Should(Equal("www.example.test."))
+			Expect(nsec.Hdr.Ttl).Should(BeNumerically("==", 60))
+			Expect(nsec.NextDomain).Should(Equal(zoneName))
+			Expect(nsec.TypeBitMap).Should(Equal([]uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}))
+		})
+
+		It("should prove NODATA of empty non-terminals with the covering record", func() {
+			records, _ := sut.Deny(zoneName, names, "b.example.test.", dns.RcodeSuccess, 60)
+
+			Expect(records).Should(HaveLen(1))
+			Expect(records[0].Header().Name).Should(Equal(zoneName))
+			Expect(records[0].(*dns.NSEC).NextDomain).Should(Equal("a.b.example.test."))
+		})
+
+		It("should prove NXDOMAIN with the records covering the name and the wildcard", func() {
+			records, rCode := sut.Deny(zoneName, names, "x.b.example.test.", dns.RcodeNameError, 60)
+
+			Expect(rCode).Should(Equal(dns.RcodeNameError))
+			Expect(records).Should(HaveLen(2))
+			Expect(covers(records[0], "x.b.example.test.")).Should(BeTrue())
+			Expect(covers(records[1], "*.b.example.test.")).Should(BeTrue())
+		})
+
+		It("should wrap around at the end of the chain", func() {
+			records, _ := sut.Deny(zoneName, names, "zzz.example.test.", dns.RcodeNameError, 60)
+
+			Expect(records[0].Header().Name).Should(Equal("www.example.test."))
+			Expect(records[0].(*dns.NSEC).NextDomain).Should(Equal(zoneName))
+		})
+
+		It("should create white lies", func() {
+			sut := Denial{Mode: DenialNSEC, WhiteLies: true}
+
+			records, _ := sut.Deny(zoneName, names, "x.b.example.test.", dns.RcodeNameError, 60)
+
+			Expect(records).Should(HaveLen(2))
+			Expect(covers(records[0], "x.b.example.test.")).Should(BeTrue())
+			Expect(covers(records[1], "*.b.example.test.")).Should(BeTrue())
+			Expect(records[0].(*dns.NSEC).NextDomain).Should(Equal("\\000.x.b.example.test."))
+
+			for _, n := range []string{zoneName, "www.example.test.", "a.b.example.test.", "b.example.test."} {
+				Expect(covers(records[0], n)).Should(BeFalse())
+				Expect(covers(records[1], n)).Should(BeFalse())
+			}
+		})
+	})
+
+	Describe("NSEC3", func() {
+		sut := Denial{Mode: DenialNSEC3, Iterations: 2, Salt: "CAFE"}
+
+		It("should prove NODATA with the matching record", func() {
+			records, rCode := sut.Deny(zoneName, names, "www.example.test.", dns.RcodeSuccess, 60)
+
+			Expect(rCode).Should(Equal(dns.RcodeSuccess))
+			Expect(records).Should(HaveLen(1))
+
+			nsec3 := records[0].(*dns.NSEC3)
+			Expect(nsec3.Match("www.example.test.")).Should(BeTrue())
+			Expect(nsec3.Iterations).Should(BeNumerically("==", 2))
+			Expect(nsec3.Salt).Should(Equal("CAFE"))
+			Expect(nsec3.SaltLength).Should(BeNumerically("==", 2))
+			Expect(nsec3.TypeBitMap).Should(Equal([]uint16{dns.TypeA, dns.TypeRRSIG}))
+		})
+
+		It("should prove NXDOMAIN with the closest encloser proof", func() {
+			records, _ := sut.Deny(zoneName, names, "x.y.b.example.test.", dns.RcodeNameError, 60)
+
+			Expect(records).Should(HaveLen(3))
+			Expect(nsec3Matches(records, "b.example.test.")).Should(BeTrue())
+			Expect(nsec3Covers(records, "y.b.example.test.")).Should(BeTrue())
+			Expect(nsec3Covers(records, "*.b.example.test.")).Should(BeTrue())
+		})
+
+		It("should create white lies", func() {
+			sut := Denial{Mode: DenialNSEC3, WhiteLies: true}
+
+			records, _ := sut.Deny(zoneName, names, "x.example.test.", dns.RcodeNameError, 60)
+
+			Expect(nsec3Matches(records, zoneName)).Should(BeTrue())
+			Expect(nsec3Covers(records, "x.example.test.")).Should(BeTrue())
+			Expect(nsec3Covers(records, "*.example.test.")).Should(BeTrue())
+
+			for n := range names {
+				Expect(nsec3Covers(records, n)).Should(BeFalse(), n)
+			}
+		})
+
+		It("should omit insecure delegations with opt-out", func() {
+			sut := Denial{Mode: DenialNSEC3, OptOut: true}
+
+			records, _ := sut.Deny(zoneName, names, "sub.example.test.", dns.RcodeNameError, 60)
+
+			Expect(nsec3Covers(records, "sub.example.test.")).Should(BeTrue())
+			Expect(records[0].(*dns.NSEC3).Flags).Should(BeNumerically("==", 1))
+		})
+
+		It("should return the parameters", func() {
+			param := sut.NSEC3PARAM(zoneName)
+
+			Expect(param.Iterations).Should(BeNumerically("==", 2))
+			Expect(param.Salt).Should(Equal("CAFE"))
+		})
+	})
+
+	Describe("compact", func() {
+		sut := Denial{Mode: DenialCompact}
+
+		It("should answer NXDOMAIN with NOERROR and NXNAME", func() {
+			records, rCode := sut.Deny(zoneName, names, "x.example.test.", dns.RcodeNameError, 60)
+
+			Expect(rCode).Should(Equal(dns.RcodeSuccess))
+			Expect(records).Should(HaveLen(1))
+
+			nsec := records[0].(*dns.NSEC)
+			Expect(nsec.Hdr.Name).Should(Equal("x.example.test."))
+			Expect(nsec.NextDomain).Should(Equal("\\000.x.example.test."))
+			Expect(nsec.TypeBitMap).Should(Equal([]uint16{dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNXNAME}))
+		})
+
+		It("should answer NODATA with the types of the name", func() {
+			records, _ := sut.Deny(zoneName, names, "www.example.test.", dns.RcodeSuccess, 60)
+
+			Expect(records[0].(*dns.NSEC).TypeBitMap).Should(Equal([]uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}))
+		})
+	})
+
+	Describe("incrementHash", func() {
+		It("should carry and wrap around", func() {
+			Expect(incrementHash("0000000000000000000000000000000V", 1)).Should(Equal("00000000000000000000000000000010"))
+			Expect(incrementHash("00000000000000000000000000000010", -1)).Should(Equal("0000000000000000000000000000000V"))
+			Expect(incrementHash("00000000000000000000000000000000", -1)).Should(Equal("VVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVV"))
+		})
+	})
+})
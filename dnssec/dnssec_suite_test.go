@@ -0,0 +1,13 @@
+package dnssec
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDNSSEC(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DNSSEC Suite")
+}
@@ -0,0 +1,210 @@
+package dnssec
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	flagsZSK = 256
+	flagsKSK = 257
+	// KeyTTL is the TTL of the DNSKEY and DS records
+	KeyTTL       = 3600
+	protocol     = 3
+	keyFileMode  = 0o600
+	rsaKeyBits   = 2048
+	ecdsaP256    = 256
+	ecdsaP384    = 384
+	ed25519Bits  = 256
+	rootFileName = "root"
+)
+
+// Key is a DNSKEY with its private key
+type Key struct {
+	DNSKEY *dns.DNSKEY
+	Signer crypto.Signer
+}
+
+// ZoneKeys contains the key signing key (KSK) and the zone signing key (ZSK) of a zone
+type ZoneKeys struct {
+	// Zone is the fully qualified lower case name of the zone apex
+	Zone string
+	KSK  Key
+	ZSK  Key
+}
+
+// GenerateKeys creates a new KSK and ZSK for the zone with the algorithm
+func GenerateKeys(zone string, algorithm uint8) (*ZoneKeys, error) {
+	zone = dns.CanonicalName(zone)
+
+	ksk, err := generateKey(zone, algorithm, flagsKSK)
+	if err != nil {
+		return nil, err
+	}
+
+	zsk, err := generateKey(zone, algorithm, flagsZSK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZoneKeys{Zone: zone, KSK: ksk, ZSK: zsk}, nil
+}
+
+func generateKey(zone string, algorithm uint8, flags uint16) (Key, error) {
+	bits, err := keyBits(algorithm)
+	if err != nil {
+		return Key{}, err
+	}
+
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: KeyTTL},
+		Flags:     flags,
+		Protocol:  protocol,
+		Algorithm: algorithm,
+	}
+
+	private, err := k.Generate(bits)
+	if err != nil {
+		return Key{}, fmt.Errorf("can't generate key for zone '%s': %w", zone, err)
+	}
+
+	return newKey(k, private)
+}
+
+// keyBits returns the key size of the supported algorithms
+func keyBits(algorithm uint8) (int, error) {
+	switch algorithm {
+	case dns.RSASHA256:
+		return rsaKeyBits, nil
+	case dns.ECDSAP256SHA256:
+		return ecdsaP256, nil
+	case dns.ECDSAP384SHA384:
+		return ecdsaP384, nil
+	case dns.ED25519:
+		return ed25519Bits, nil
+	}
+
+	return 0, fmt.Errorf("unsupported DNSSEC algorithm '%s'", dns.AlgorithmToString[algorithm])
+}
+
+func newKey(k *dns.DNSKEY, private crypto.PrivateKey) (Key, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("private key of zone '%s' can't sign", k.Hdr.Name)
+	}
+
+	return Key{DNSKEY: k, Signer: signer}, nil
+}
+
+// LoadKeys reads the KSK and ZSK of the zone from the directory. If the keys don't exist, they are generated
+// with the algorithm and written to the directory, so that the keys (and the DS record) stay the same after restarts.
+func LoadKeys(dir, zone string, algorithm uint8) (*ZoneKeys, error) {
+	zone = dns.CanonicalName(zone)
+
+	ksk, err := readKey(keyFile(dir, zone, "ksk"))
+	if errors.Is(err, fs.ErrNotExist) {
+		keys, err := GenerateKeys(zone, algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		return keys, keys.Save(dir)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	zsk, err := readKey(keyFile(dir, zone, "zsk"))
+	if err != nil {
+		return nil, err
+	}
+
+	if ksk.DNSKEY.Hdr.Name != zone || zsk.DNSKEY.Hdr.Name != zone {
+		return nil, fmt.Errorf("keys in '%s' don't belong to zone '%s'", dir, zone)
+	}
+
+	return &ZoneKeys{Zone: zone, KSK: ksk, ZSK: zsk}, nil
+}
+
+// Save writes the public keys (.key) and private keys (.private) of the KSK and ZSK to the directory
+func (z *ZoneKeys) Save(dir string) error {
+	for kind, k := range map[string]Key{"ksk": z.KSK, "zsk": z.ZSK} {
+		base := keyFile(dir, z.Zone, kind)
+
+		if err := os.WriteFile(base+".key", []byte(k.DNSKEY.String()+"\n"), keyFileMode); err != nil {
+			return fmt.Errorf("can't write key of zone '%s': %w", z.Zone, err)
+		}
+
+		private := k.DNSKEY.PrivateKeyString(k.Signer)
+		if err := os.WriteFile(base+".private", []byte(private), keyFileMode); err != nil {
+			return fmt.Errorf("can't write private key of zone '%s': %w", z.Zone, err)
+		}
+	}
+
+	return nil
+}
+
+// readKey reads the public key and the private key of a key file pair
+func readKey(base string) (Key, error) {
+	public, err := os.ReadFile(base + ".key")
+	if err != nil {
+		return Key{}, err
+	}
+
+	rr, err := dns.NewRR(string(public))
+	if err != nil {
+		return Key{}, fmt.Errorf("can't parse key '%s': %w", base, err)
+	}
+
+	k, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return Key{}, fmt.Errorf("key '%s' is not a DNSKEY record", base)
+	}
+
+	k.Hdr.Name = dns.CanonicalName(k.Hdr.Name)
+
+	f, err := os.Open(base + ".private")
+	if err != nil {
+		return Key{}, err
+	}
+
+	defer f.Close()
+
+	private, err := k.ReadPrivateKey(f, base+".private")
+	if err != nil {
+		return Key{}, fmt.Errorf("can't parse private key '%s': %w", base, err)
+	}
+
+	return newKey(k, private)
+}
+
+// keyFile returns the path of a key without extension, for example "dir/example.com.ksk"
+func keyFile(dir, zone, kind string) string {
+	name := strings.TrimSuffix(zone, ".")
+	if name == "" {
+		name = rootFileName
+	}
+
+	return filepath.Join(dir, name+"."+kind)
+}
+
+// DNSKEYs returns the DNSKEY records of the KSK and ZSK
+func (z *ZoneKeys) DNSKEYs() []dns.RR {
+	return []dns.RR{z.KSK.DNSKEY, z.ZSK.DNSKEY}
+}
+
+// DS returns the delegation signer record (SHA-256 digest) of the KSK
+func (z *ZoneKeys) DS() *dns.DS {
+	ds := z.KSK.DNSKEY.ToDS(dns.SHA256)
+	ds.Hdr.Ttl = KeyTTL
+
+	return ds
+}
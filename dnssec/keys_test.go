@@ -0,0 +1,93 @@
+package dnssec
+
+import (
+	"os"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keys", func() {
+	Describe("GenerateKeys", func() {
+		DescribeTable("should generate KSK and ZSK",
+			func(algorithm uint8) {
+				keys, err := GenerateKeys("Example.TEST", algorithm)
+				Expect(err).Should(Succeed())
+
+				Expect(keys.Zone).Should(Equal("example.test."))
+				Expect(keys.KSK.DNSKEY.Flags).Should(BeNumerically("==", 257))
+				Expect(keys.ZSK.DNSKEY.Flags).Should(BeNumerically("==", 256))
+				Expect(keys.KSK.DNSKEY.Algorithm).Should(Equal(algorithm))
+				Expect(keys.DNSKEYs()).Should(HaveLen(2))
+			},
+			Entry("RSASHA256", dns.RSASHA256),
+			Entry("ECDSAP256SHA256", dns.ECDSAP256SHA256),
+			Entry("ECDSAP384SHA384", dns.ECDSAP384SHA384),
+			Entry("ED25519", dns.ED25519),
+		)
+
+		It("should fail for unsupported algorithm", func() {
+			_, err := GenerateKeys("example.test", dns.DSA)
+			Expect(err).Should(MatchError(ContainSubstring("unsupported DNSSEC algorithm")))
+		})
+	})
+
+	Describe("DS", func() {
+		It("should create the digest of the KSK", func() {
+			keys, err := GenerateKeys("example.test", dns.ECDSAP256SHA256)
+			Expect(err).Should(Succeed())
+
+			ds := keys.DS()
+			Expect(ds.Hdr.Name).Should(Equal("example.test."))
+			Expect(ds.KeyTag).Should(Equal(keys.KSK.DNSKEY.KeyTag()))
+			Expect(ds.DigestType).Should(BeNumerically("==", dns.SHA256))
+		})
+	})
+
+	Describe("LoadKeys", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+
+			dir, err = os.MkdirTemp("", "keys")
+			Expect(err).Should(Succeed())
+			DeferCleanup(os.RemoveAll, dir)
+		})
+
+		It("should generate and save the keys if they don't exist", func() {
+			keys, err := LoadKeys(dir, "example.test", dns.ED25519)
+			Expect(err).Should(Succeed())
+
+			Expect(keyFile(dir, "example.test.", "ksk") + ".key").Should(BeAnExistingFile())
+			Expect(keyFile(dir, "example.test.", "zsk") + ".private").Should(BeAnExistingFile())
+
+			loaded, err := LoadKeys(dir, "example.test.", dns.ECDSAP256SHA256)
+			Expect(err).Should(Succeed())
+			Expect(loaded.KSK.DNSKEY.String()).Should(Equal(keys.KSK.DNSKEY.String()))
+			Expect(loaded.ZSK.DNSKEY.String()).Should(Equal(keys.ZSK.DNSKEY.String()))
+
+			sig, err := loaded.ZSK.Sign([]dns.RR{newRR("www.example.test. 300 IN A 192.0.2.1")},
+				SignatureValid, now)
+			Expect(err).Should(Succeed())
+			Expect(sig.Verify(keys.ZSK.DNSKEY, []dns.RR{newRR("www.example.test. 300 IN A 192.0.2.1")})).
+				Should(Succeed())
+		})
+
+		It("should fail if the private key is missing", func() {
+			keys, err := LoadKeys(dir, "example.test", dns.ED25519)
+			Expect(err).Should(Succeed())
+			Expect(keys).ShouldNot(BeNil())
+
+			Expect(os.Remove(keyFile(dir, "example.test.", "zsk") + ".private")).Should(Succeed())
+
+			_, err = LoadKeys(dir, "example.test", dns.ED25519)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should use 'root' as file name of the root zone", func() {
+			Expect(keyFile("dir", ".", "ksk")).Should(Equal("dir/root.ksk"))
+		})
+	})
+})
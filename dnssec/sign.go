@@ -0,0 +1,237 @@
+package dnssec
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// signature modes, the deliberately broken signatures are used to test validators
+const (
+	// SignatureValid creates signatures which are valid for a week
+	SignatureValid = ""
+	// SignatureExpired creates signatures whose validity period ended a week ago
+	SignatureExpired = "expired"
+	// SignatureNotYetValid creates signatures whose validity period starts in a week
+	SignatureNotYetValid = "notyetvalid"
+	// SignatureBogus creates signatures with a valid period, but a wrong signature value
+	SignatureBogus = "bogus"
+	// SignatureNone leaves the records unsigned
+	SignatureNone = "unsigned"
+)
+
+const (
+	validity = 7 * 24 * time.Hour
+	// clockSkew is subtracted from the inception of valid signatures
+	clockSkew = time.Hour
+)
+
+// Signer signs records with the keys of the zone they belong to
+type Signer struct {
+	zones map[string]*ZoneKeys
+	// Now returns the current time, the validity period of the signatures is relative to it
+	Now func() time.Time
+}
+
+// NewSigner creates a signer for the zones
+func NewSigner(keys ...*ZoneKeys) *Signer {
+	s := &Signer{
+		zones: make(map[string]*ZoneKeys, len(keys)),
+		Now:   time.Now,
+	}
+
+	for _, k := range keys {
+		s.zones[k.Zone] = k
+	}
+
+	return s
+}
+
+// Zones returns the keys of all signed zones
+func (s *Signer) Zones() []*ZoneKeys {
+	result := make([]*ZoneKeys, 0, len(s.zones))
+
+	for _, k := range s.zones {
+		result = append(result, k)
+	}
+
+	return result
+}
+
+// Keys returns the keys of the signed zone with the apex name or nil if the zone is not signed
+func (s *Signer) Keys(apex string) *ZoneKeys {
+	return s.zones[dns.CanonicalName(apex)]
+}
+
+// Zone returns the keys of the closest signed zone which contains the name or nil if the name is not
+// in a signed zone
+func (s *Signer) Zone(name string) *ZoneKeys {
+	name = dns.CanonicalName(name)
+
+	for {
+		if k, ok := s.zones[name]; ok {
+			return k
+		}
+
+		if name == "." {
+			return nil
+		}
+
+		name = parentName(name)
+	}
+}
+
+// SignRecords returns the records followed by the signatures of their RRsets. Only RRsets in signed zones are
+// signed, the DS records of a zone cut are signed by the parent zone. If types are given, only RRsets of these types
+// are signed.
+func (s *Signer) SignRecords(records []dns.RR, mode string, types ...uint16) ([]dns.RR, error) {
+	if mode == SignatureNone {
+		return records, nil
+	}
+
+	var signatures []dns.RR
+
+	for _, rrset := range rrsets(records) {
+		rType := rrset[0].Header().Rrtype
+
+		if !signable(rType) || (len(types) > 0 && !slices.Contains(types, rType)) {
+			continue
+		}
+
+		owner := dns.CanonicalName(rrset[0].Header().Name)
+
+		keys := s.Zone(owner)
+		if rType == dns.TypeDS && owner != "." {
+			keys = s.Zone(parentName(owner))
+		}
+
+		if keys == nil {
+			continue
+		}
+
+		key := keys.ZSK
+		if rType == dns.TypeDNSKEY && owner == keys.Zone {
+			key = keys.KSK
+		}
+
+		sig, err := key.Sign(rrset, mode, s.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, sig)
+	}
+
+	if len(signatures) == 0 {
+		return records, nil
+	}
+
+	// the records can be shared with a zone, so they are copied instead of appending to them
+	result := make([]dns.RR, 0, len(records)+len(signatures))
+	result = append(result, records...)
+
+	return append(result, signatures...), nil
+}
+
+// Sign returns the RRSIG of the RRset, the validity period and the signature value depend on the mode
+func (k Key) Sign(rrset []dns.RR, mode string, now time.Time) (*dns.RRSIG, error) {
+	inception, expiration := now.Add(-clockSkew), now.Add(validity)
+
+	switch mode {
+	case SignatureExpired:
+		inception, expiration = now.Add(-2*validity), now.Add(-validity)
+	case SignatureNotYetValid:
+		inception, expiration = now.Add(validity), now.Add(2*validity)
+	}
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Ttl: minTTL(rrset)},
+		Algorithm:  k.DNSKEY.Algorithm,
+		KeyTag:     k.DNSKEY.KeyTag(),
+		SignerName: k.DNSKEY.Hdr.Name,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+		OrigTtl:    minTTL(rrset),
+	}
+
+	if err := sig.Sign(k.Signer, rrset); err != nil {
+		return nil, fmt.Errorf("can't sign '%s': %w", rrset[0].Header().Name, err)
+	}
+
+	if mode == SignatureBogus {
+		sig.Signature = corrupt(sig.Signature)
+	}
+
+	return sig, nil
+}
+
+// corrupt inverts the first byte of the base64 encoded signature
+func corrupt(signature string) string {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(raw) == 0 {
+		return signature
+	}
+
+	raw[0] ^= 0xFF
+
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// rrsets groups the records by owner, type and class in the order of their first occurrence
+func rrsets(records []dns.RR) [][]dns.RR {
+	var (
+		result [][]dns.RR
+		index  = make(map[string]int)
+	)
+
+	for _, rr := range records {
+		h := rr.Header()
+		key := fmt.Sprintf("%s/%d/%d", dns.CanonicalName(h.Name), h.Rrtype, h.Class)
+
+		if i, ok := index[key]; ok {
+			result[i] = append(result[i], rr)
+
+			continue
+		}
+
+		index[key] = len(result)
+		result = append(result, []dns.RR{rr})
+	}
+
+	return result
+}
+
+// signable checks if records of the type are signed, signatures and meta records are not signed
+func signable(rType uint16) bool {
+	switch rType {
+	case dns.TypeRRSIG, dns.TypeOPT, dns.TypeTSIG, dns.TypeSIG:
+		return false
+	}
+
+	return true
+}
+
+func minTTL(rrset []dns.RR) uint32 {
+	ttl := rrset[0].Header().Ttl
+
+	for _, rr := range rrset[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	return ttl
+}
+
+// parentName returns the name without its first label, the parent of a top level name is the root
+func parentName(name string) string {
+	if i := strings.Index(name, "."); i >= 0 && i < len(name)-1 {
+		return name[i+1:]
+	}
+
+	return "."
+}
@@ -0,0 +1,167 @@
+package dnssec
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newRR(in string) dns.RR {
+	rr, err := dns.NewRR(in)
+	Expect(err).Should(Succeed())
+
+	return rr
+}
+
+func signatures(records []dns.RR) []*dns.RRSIG {
+	var result []*dns.RRSIG
+
+	for _, rr := range records {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			result = append(result, sig)
+		}
+	}
+
+	return result
+}
+
+var _ = Describe("Signer", func() {
+	var (
+		parent, child *ZoneKeys
+		sut           *Signer
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		parent, err = GenerateKeys("example.test", dns.ECDSAP256SHA256)
+		Expect(err).Should(Succeed())
+
+		child, err = GenerateKeys("sub.example.test", dns.ECDSAP256SHA256)
+		Expect(err).Should(Succeed())
+
+		sut = NewSigner(parent, child)
+		sut.Now = func() time.Time { return now }
+	})
+
+	Describe("Zone", func() {
+		It("should return the closest signed zone", func() {
+			Expect(sut.Zone("www.example.test.")).Should(Equal(parent))
+			Expect(sut.Zone("WWW.sub.example.test.")).Should(Equal(child))
+			Expect(sut.Zone("sub.example.test.")).Should(Equal(child))
+			Expect(sut.Zone("other.test.")).Should(BeNil())
+		})
+	})
+
+	Describe("SignRecords", func() {
+		It("should sign each RRset with the ZSK of its zone", func() {
+			records := []dns.RR{
+				newRR("www.example.test. 300 IN A 192.0.2.1"),
+				newRR("www.sub.example.test. 300 IN A 192.0.2.2"),
+				newRR("www.example.test. 300 IN A 192.0.2.3"),
+			}
+
+			signed, err := sut.SignRecords(records, SignatureValid)
+			Expect(err).Should(Succeed())
+			Expect(signed).Should(HaveLen(5))
+
+			sigs := signatures(signed)
+			Expect(sigs[0].SignerName).Should(Equal("example.test."))
+			Expect(sigs[0].Verify(parent.ZSK.DNSKEY, []dns.RR{records[0], records[2]})).Should(Succeed())
+			Expect(sigs[0].ValidityPeriod(now)).Should(BeTrue())
+			Expect(sigs[1].SignerName).Should(Equal("sub.example.test."))
+			Expect(sigs[1].Verify(child.ZSK.DNSKEY, []dns.RR{records[1]})).Should(Succeed())
+		})
+
+		It("should sign DNSKEY with the KSK and DS with the parent zone", func() {
+			records := append(child.DNSKEYs(), child.DS())
+
+			signed, err := sut.SignRecords(records, SignatureValid)
+			Expect(err).Should(Succeed())
+
+			sigs := signatures(signed)
+			Expect(sigs).Should(HaveLen(2))
+			Expect(sigs[0].Verify(child.KSK.DNSKEY, child.DNSKEYs())).Should(Succeed())
+			Expect(sigs[1].SignerName).Should(Equal("example.test."))
+			Expect(sigs[1].Verify(parent.ZSK.DNSKEY, []dns.RR{child.DS()})).Should(Succeed())
+		})
+
+		It("should not sign records outside of signed zones and signatures", func() {
+			records := []dns.RR{
+				newRR("www.other.test. 300 IN A 192.0.2.1"),
+				newRR("www.example.test. 300 IN RRSIG A 13 3 300 20240101000000 20230101000000 1 example.test. AAAA"),
+			}
+
+			signed, err := sut.SignRecords(records, SignatureValid)
+			Expect(err).Should(Succeed())
+			Expect(signed).Should(Equal(records))
+		})
+
+		It("should sign only the RRsets of the types", func() {
+			records := []dns.RR{
+				newRR("sub.example.test. 300 IN NS ns1.sub.example.test."),
+				child.DS(),
+			}
+
+			signed, err := sut.SignRecords(records, SignatureValid, dns.TypeDS)
+			Expect(err).Should(Succeed())
+			Expect(signatures(signed)).Should(HaveLen(1))
+			Expect(signatures(signed)[0].TypeCovered).Should(Equal(dns.TypeDS))
+		})
+
+		It("should not sign records in unsigned mode", func() {
+			records := []dns.RR{newRR("www.example.test. 300 IN A 192.0.2.1")}
+
+			signed, err := sut.SignRecords(records, SignatureNone)
+			Expect(err).Should(Succeed())
+			Expect(signed).Should(Equal(records))
+		})
+	})
+
+	Describe("Sign", func() {
+		var rrset []dns.RR
+
+		BeforeEach(func() {
+			rrset = []dns.RR{newRR("www.example.test. 300 IN A 192.0.2.1")}
+		})
+
+		It("should create expired signatures", func() {
+			sig, err := parent.ZSK.Sign(rrset, SignatureExpired, now)
+			Expect(err).Should(Succeed())
+
+			Expect(sig.Verify(parent.ZSK.DNSKEY, rrset)).Should(Succeed())
+			Expect(sig.ValidityPeriod(now)).Should(BeFalse())
+			Expect(int64(sig.Expiration)).Should(BeNumerically("<", now.Unix()))
+		})
+
+		It("should create signatures which are not yet valid", func() {
+			sig, err := parent.ZSK.Sign(rrset, SignatureNotYetValid, now)
+			Expect(err).Should(Succeed())
+
+			Expect(sig.Verify(parent.ZSK.DNSKEY, rrset)).Should(Succeed())
+			Expect(sig.ValidityPeriod(now)).Should(BeFalse())
+			Expect(int64(sig.Inception)).Should(BeNumerically(">", now.Unix()))
+		})
+
+		It("should create bogus signatures", func() {
+			sig, err := parent.ZSK.Sign(rrset, SignatureBogus, now)
+			Expect(err).Should(Succeed())
+
+			Expect(sig.ValidityPeriod(now)).Should(BeTrue())
+			Expect(sig.Verify(parent.ZSK.DNSKEY, rrset)).ShouldNot(Succeed())
+		})
+
+		It("should use the lowest TTL of the RRset", func() {
+			rrset = append(rrset, newRR("www.example.test. 60 IN A 192.0.2.2"))
+
+			sig, err := parent.ZSK.Sign(rrset, SignatureValid, now)
+			Expect(err).Should(Succeed())
+			Expect(sig.Hdr.Ttl).Should(BeNumerically("==", 60))
+			Expect(sig.Labels).Should(BeNumerically("==", 3))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package mock
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -16,7 +17,23 @@ type ResolveFn func(request *dns.Msg) *dns.Msg
 type Result struct {
 	RCode int
 	RR    []Record
-	Err   error
+	// ZoneFiles contains the master files of a zone, ordered from the oldest to the current version
+	ZoneFiles []string
+	// Drop is set if the request is not answered, for example to test timeouts of clients
+	Drop bool
+	// Forward is set if the question is forwarded to an upstream resolver
+	Forward *Forward
+	Err     error
+}
+
+// Forward defines the upstream resolver and the changes of its response
+type Forward struct {
+	// Upstream is the address (host:port) of the resolver, the global upstream is used if empty
+	Upstream string
+	// TTL replaces the TTL of all records if set
+	TTL *uint32
+	// Filter contains the types of the records which are removed from the response
+	Filter []uint16
 }
 
 type Record struct {
@@ -31,6 +48,85 @@ func nxdomain() Result {
 	}
 }
 
+func refused() Result {
+	return Result{
+		RCode: dns.RcodeRefused,
+	}
+}
+
+func notauth() Result {
+	return Result{
+		RCode: dns.RcodeNotAuth,
+	}
+}
+
+func drop() Result {
+	return Result{
+		Drop: true,
+	}
+}
+
+// forward parses the upstream address and the options ("ttl=60", "filter=AAAA,HTTPS"), the global upstream is used
+// if no address is given
+func forward(in ...string) Result {
+	f := &Forward{}
+
+	for _, arg := range in {
+		key, value, isOption := strings.Cut(arg, "=")
+		if !isOption {
+			if arg != "" {
+				f.Upstream = UpstreamAddress(arg)
+			}
+
+			continue
+		}
+
+		if err := f.applyOption(key, value); err != nil {
+			return Result{Err: err}
+		}
+	}
+
+	return Result{
+		RCode:   dns.RcodeSuccess,
+		Forward: f,
+	}
+}
+
+func (f *Forward) applyOption(key, value string) error {
+	switch key {
+	case "ttl":
+		ttl, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("can't parse TTL '%s': %w", value, err)
+		}
+
+		t := uint32(ttl)
+		f.TTL = &t
+	case "filter":
+		for _, t := range strings.Split(value, ",") {
+			rType, found := dns.StringToType[strings.ToUpper(strings.TrimSpace(t))]
+			if !found {
+				return fmt.Errorf("unknown type '%s'", t)
+			}
+
+			f.Filter = append(f.Filter, rType)
+		}
+	default:
+		return fmt.Errorf("unknown FORWARD option '%s'", key)
+	}
+
+	return nil
+}
+
+// UpstreamAddress adds the default DNS port to an address without port
+func UpstreamAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(strings.Trim(address, "[]"), "53")
+	}
+
+	return address
+}
+
 func noerror(in ...string) Result {
 	var rr = make([]Record, len(in))
 
@@ -49,6 +145,24 @@ func noerror(in ...string) Result {
 	}
 }
 
+func transfer(files ...string) Result {
+	if len(files) == 0 {
+		return Result{Err: fmt.Errorf("at least one zone file is required")}
+	}
+
+	return Result{
+		RCode:     dns.RcodeSuccess,
+		ZoneFiles: files,
+	}
+}
+
+func zoneFile(file string) Result {
+	return Result{
+		RCode:     dns.RcodeSuccess,
+		ZoneFiles: []string{file},
+	}
+}
+
 func delay(fn Result, duration ...string) Result {
 	d := time.Second
 
@@ -110,6 +224,30 @@ func CreateEnv() (*env.Env, error) {
 		return nil, err
 	}
 
+	if err := e.Define("REFUSED", refused); err != nil {
+		return nil, err
+	}
+
+	if err := e.Define("NOTAUTH", notauth); err != nil {
+		return nil, err
+	}
+
+	if err := e.Define("DROP", drop); err != nil {
+		return nil, err
+	}
+
+	if err := e.Define("FORWARD", forward); err != nil {
+		return nil, err
+	}
+
+	if err := e.Define("TRANSFER", transfer); err != nil {
+		return nil, err
+	}
+
+	if err := e.Define("ZONE", zoneFile); err != nil {
+		return nil, err
+	}
+
 	if err := e.Define("delay", delay); err != nil {
 		return nil, err
 	}
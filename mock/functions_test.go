@@ -29,6 +29,73 @@ var _ = Describe("Functions", func() {
 			})
 		})
 
+		When("REFUSED() is executed", func() {
+			It("should return refused", func() {
+				execute, err := vm.Execute(e, nil, "REFUSED()")
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.RCode).Should(Equal(dns.RcodeRefused))
+			})
+		})
+
+		When("DROP() is executed", func() {
+			It("should drop the request", func() {
+				execute, err := vm.Execute(e, nil, "DROP()")
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.Drop).Should(BeTrue())
+			})
+		})
+
+		When("FORWARD() is executed", func() {
+			It("should return the upstream with default port and the options", func() {
+				execute, err := vm.Execute(e, nil, `FORWARD("127.0.0.1", "ttl=60", "filter=AAAA,https")`)
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.Forward.Upstream).Should(Equal("127.0.0.1:53"))
+				Expect(*result.Forward.TTL).Should(BeEquivalentTo(60))
+				Expect(result.Forward.Filter).Should(Equal([]uint16{dns.TypeAAAA, dns.TypeHTTPS}))
+			})
+
+			It("should use the global upstream without address", func() {
+				execute, err := vm.Execute(e, nil, `FORWARD("ttl=0")`)
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.Forward.Upstream).Should(BeEmpty())
+				Expect(*result.Forward.TTL).Should(BeZero())
+			})
+
+			It("should keep the port of IPv6 addresses", func() {
+				Expect(mock.UpstreamAddress("[::1]:5353")).Should(Equal("[::1]:5353"))
+				Expect(mock.UpstreamAddress("::1")).Should(Equal("[::1]:53"))
+			})
+
+			It("should fail with unknown option", func() {
+				execute, err := vm.Execute(e, nil, `FORWARD("127.0.0.1:5353", "foo=1")`)
+				Expect(err).Should(Succeed())
+				Expect(execute.(mock.Result).Err).Should(MatchError("unknown FORWARD option 'foo'"))
+			})
+		})
+
+		When("NOTAUTH() is executed", func() {
+			It("should return notauth", func() {
+				execute, err := vm.Execute(e, nil, "NOTAUTH()")
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.RCode).Should(Equal(dns.RcodeNotAuth))
+			})
+		})
+
 		When("NOERROR() is executed", func() {
 			It("should return valid response", func() {
 				execute, err := vm.Execute(e, nil, `NOERROR("A 1.2.3.4 123")`)
@@ -128,6 +195,38 @@ var _ = Describe("Functions", func() {
 			})
 		})
 
+		When("TRANSFER() is executed", func() {
+			It("should return the zone files", func() {
+				execute, err := vm.Execute(e, nil, `TRANSFER("v1.zone", "v2.zone")`)
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.RCode).Should(Equal(dns.RcodeSuccess))
+				Expect(result.ZoneFiles).Should(Equal([]string{"v1.zone", "v2.zone"}))
+			})
+
+			It("should return error without zone file", func() {
+				execute, err := vm.Execute(e, nil, `TRANSFER()`)
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(HaveOccurred())
+			})
+		})
+
+		When("ZONE() is executed", func() {
+			It("should return the zone file", func() {
+				execute, err := vm.Execute(e, nil, `ZONE("example.com.zone")`)
+				Expect(err).Should(Succeed())
+				result := execute.(mock.Result)
+
+				Expect(result.Err).Should(BeNil())
+				Expect(result.RCode).Should(Equal(dns.RcodeSuccess))
+				Expect(result.ZoneFiles).Should(Equal([]string{"example.com.zone"}))
+			})
+		})
+
 		When("delay() is executed", func() {
 			It("should delay the response", func() {
 				start := time.Now()
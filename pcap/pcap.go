@@ -0,0 +1,274 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// magicNanoseconds is the magic number of pcap files with nanosecond timestamps
+	magicNanoseconds = 0xa1b23c4d
+	versionMajor     = 2
+	versionMinor     = 4
+	snapLength       = 262144
+	// linkTypeRaw is the link type of packets which start with the IPv4 or IPv6 header
+	linkTypeRaw = 101
+
+	fileHeaderLength   = 24
+	recordHeaderLength = 16
+	ipv4HeaderLength   = 20
+	ipv6HeaderLength   = 40
+	udpHeaderLength    = 8
+	tcpHeaderLength    = 20
+	// pseudoHeaderLength is the length of the checksum pseudo header with IPv6 addresses
+	pseudoHeaderLength = 2*16 + 4
+
+	protocolTCP = 6
+	protocolUDP = 17
+	hopLimit    = 64
+
+	ipv4DontFragment = 0x4000
+	tcpFlagsPSHACK   = 0x18
+	tcpWindow        = 65535
+	// maxFlows limits the number of TCP connections with tracked sequence numbers
+	maxFlows = 10000
+	// maxLength is the maximum of the 16 bit length fields in the IP, UDP and pseudo headers
+	maxLength = 0xffff
+)
+
+var (
+	errPacketTooLarge = errors.New("packet is too large")
+	errMixedVersions  = errors.New("source and destination have different IP versions")
+)
+
+// Writer writes packets with synthesized IP, UDP and TCP headers in the pcap format,
+// it is safe for concurrent use
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+	// sequences contains the next TCP sequence number of each direction of a connection
+	sequences map[flow]uint32
+}
+
+type flow struct {
+	src, dst netip.AddrPort
+}
+
+// Create creates the file and writes the pcap file header
+func Create(path string) (*Writer, error) {
+	const fileMode = 0o644
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(f)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// NewWriter writes the pcap file header to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	header := make([]byte, fileHeaderLength)
+	binary.LittleEndian.PutUint32(header[0:], magicNanoseconds)
+	binary.LittleEndian.PutUint16(header[4:], versionMajor)
+	binary.LittleEndian.PutUint16(header[6:], versionMinor)
+	binary.LittleEndian.PutUint32(header[16:], snapLength)
+	binary.LittleEndian.PutUint32(header[20:], linkTypeRaw)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w, sequences: make(map[flow]uint32)}, nil
+}
+
+// WriteUDP writes a UDP datagram from src to dst, both addresses must have the same IP version.
+// A datagram which doesn't fit into an IP packet without fragmentation is rejected.
+func (w *Writer) WriteUDP(t time.Time, src, dst netip.AddrPort, payload []byte) error {
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		return errMixedVersions
+	}
+
+	if err := checkLength(src.Addr(), udpHeaderLength+len(payload)); err != nil {
+		return err
+	}
+
+	segment := make([]byte, udpHeaderLength+len(payload))
+	binary.BigEndian.PutUint16(segment[0:], src.Port())
+	binary.BigEndian.PutUint16(segment[2:], dst.Port())
+	binary.BigEndian.PutUint16(segment[4:], uint16(len(segment)))
+	copy(segment[udpHeaderLength:], payload)
+
+	checksum := transportChecksum(src.Addr(), dst.Addr(), protocolUDP, segment)
+	if checksum == 0 {
+		// a zero checksum means no checksum in UDP
+		checksum = 0xffff
+	}
+
+	binary.BigEndian.PutUint16(segment[6:], checksum)
+
+	return w.writePacket(t, src.Addr(), dst.Addr(), protocolUDP, segment)
+}
+
+// WriteTCP writes a TCP segment with PSH and ACK flags from src to dst, the sequence numbers continue the
+// previous segments of the connection. Both addresses must have the same IP version and the segment
+// must fit into an IP packet.
+func (w *Writer) WriteTCP(t time.Time, src, dst netip.AddrPort, payload []byte) error {
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		return errMixedVersions
+	}
+
+	if err := checkLength(src.Addr(), tcpHeaderLength+len(payload)); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	forward, backward := flow{src, dst}, flow{dst, src}
+
+	if _, known := w.sequences[forward]; !known && len(w.sequences) >= maxFlows {
+		// the sequence numbers of old connections start again
+		clear(w.sequences)
+	}
+
+	seq, ack := w.sequences[forward]+1, w.sequences[backward]+1
+	w.sequences[forward] += uint32(len(payload))
+
+	segment := make([]byte, tcpHeaderLength+len(payload))
+	binary.BigEndian.PutUint16(segment[0:], src.Port())
+	binary.BigEndian.PutUint16(segment[2:], dst.Port())
+	binary.BigEndian.PutUint32(segment[4:], seq)
+	binary.BigEndian.PutUint32(segment[8:], ack)
+	segment[12] = (tcpHeaderLength / 4) << 4
+	segment[13] = tcpFlagsPSHACK
+	binary.BigEndian.PutUint16(segment[14:], tcpWindow)
+	copy(segment[tcpHeaderLength:], payload)
+
+	binary.BigEndian.PutUint16(segment[16:], transportChecksum(src.Addr(), dst.Addr(), protocolTCP, segment))
+
+	return w.write(t, ipPacket(src.Addr(), dst.Addr(), protocolTCP, segment))
+}
+
+// Close closes the underlying writer if it is a closer
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func (w *Writer) writePacket(t time.Time, src, dst netip.Addr, protocol byte, segment []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(t, ipPacket(src, dst, protocol, segment))
+}
+
+// write writes the record header and the packet at once, so the file contains only complete records
+func (w *Writer) write(t time.Time, packet []byte) error {
+	if len(packet) > snapLength {
+		return errPacketTooLarge
+	}
+
+	record := make([]byte, recordHeaderLength+len(packet))
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	copy(record[recordHeaderLength:], packet)
+
+	_, err := w.w.Write(record)
+
+	return err
+}
+
+// checkLength checks that the lengths of the segment and of the IP packet fit into the 16 bit length fields
+func checkLength(addr netip.Addr, segmentLength int) error {
+	length := segmentLength
+	if addr.Is4() {
+		length += ipv4HeaderLength
+	}
+
+	if length > maxLength {
+		return errPacketTooLarge
+	}
+
+	return nil
+}
+
+// ipPacket prepends the IPv4 or IPv6 header to the segment
+func ipPacket(src, dst netip.Addr, protocol byte, segment []byte) []byte {
+	if src.Is4() {
+		packet := make([]byte, ipv4HeaderLength+len(segment))
+		packet[0] = 0x45 // version 4, header length 5 * 4 bytes
+		binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+		binary.BigEndian.PutUint16(packet[6:], ipv4DontFragment)
+		packet[8] = hopLimit
+		packet[9] = protocol
+		copy(packet[12:], src.AsSlice())
+		copy(packet[16:], dst.AsSlice())
+		binary.BigEndian.PutUint16(packet[10:], ^sum(0, packet[:ipv4HeaderLength]))
+		copy(packet[ipv4HeaderLength:], segment)
+
+		return packet
+	}
+
+	packet := make([]byte, ipv6HeaderLength+len(segment))
+	packet[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(packet[4:], uint16(len(segment)))
+	packet[6] = protocol
+	packet[7] = hopLimit
+	copy(packet[8:], src.AsSlice())
+	copy(packet[24:], dst.AsSlice())
+	copy(packet[ipv6HeaderLength:], segment)
+
+	return packet
+}
+
+// transportChecksum returns the UDP or TCP checksum including the pseudo header. The IPv6 pseudo header has
+// a 32 bit length and the protocol in the last byte, which results in the same sum for segments < 64K.
+func transportChecksum(src, dst netip.Addr, protocol byte, segment []byte) uint16 {
+	pseudo := make([]byte, 0, pseudoHeaderLength)
+	pseudo = append(pseudo, src.AsSlice()...)
+	pseudo = append(pseudo, dst.AsSlice()...)
+	pseudo = append(pseudo, 0, protocol)
+	pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(segment)))
+
+	return ^sum(sum(0, pseudo), segment)
+}
+
+// sum adds the 16 bit words of the data to the ones' complement sum
+func sum(initial uint16, data []byte) uint16 {
+	s := uint32(initial)
+
+	for i := 0; i+1 < len(data); i += 2 {
+		s += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+
+	if len(data)%2 == 1 {
+		s += uint32(data[len(data)-1]) << 8
+	}
+
+	for s > 0xffff {
+		s = s&0xffff + s>>16
+	}
+
+	return uint16(s)
+}
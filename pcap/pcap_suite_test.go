@@ -0,0 +1,13 @@
+package pcap
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPcap(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pcap Suite")
+}
@@ -0,0 +1,131 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer", func() {
+	var (
+		buf *bytes.Buffer
+		w   *Writer
+		ts  = time.Unix(1700000000, 123456789)
+	)
+
+	// packets returns the packets of the records after the file header
+	packets := func() [][]byte {
+		data := buf.Bytes()[fileHeaderLength:]
+
+		var result [][]byte
+
+		for len(data) > 0 {
+			Expect(binary.LittleEndian.Uint32(data[0:])).Should(BeEquivalentTo(ts.Unix()))
+			Expect(binary.LittleEndian.Uint32(data[4:])).Should(BeEquivalentTo(ts.Nanosecond()))
+
+			length := binary.LittleEndian.Uint32(data[8:])
+			Expect(binary.LittleEndian.Uint32(data[12:])).Should(Equal(length))
+
+			result = append(result, data[recordHeaderLength:recordHeaderLength+length])
+			data = data[recordHeaderLength+length:]
+		}
+
+		return result
+	}
+
+	BeforeEach(func() {
+		buf = new(bytes.Buffer)
+
+		var err error
+		w, err = NewWriter(buf)
+		Expect(err).Should(Succeed())
+	})
+
+	It("should write the file header", func() {
+		Expect(buf.Bytes()).Should(HaveLen(fileHeaderLength))
+		Expect(binary.LittleEndian.Uint32(buf.Bytes()[0:])).Should(BeEquivalentTo(magicNanoseconds))
+		Expect(binary.LittleEndian.Uint32(buf.Bytes()[20:])).Should(BeEquivalentTo(linkTypeRaw))
+	})
+
+	It("should write UDP datagrams with IPv4 header", func() {
+		src := netip.MustParseAddrPort("192.0.2.1:40000")
+		dst := netip.MustParseAddrPort("192.0.2.53:53")
+
+		Expect(w.WriteUDP(ts, src, dst, []byte("query"))).Should(Succeed())
+
+		p := packets()
+		Expect(p).Should(HaveLen(1))
+
+		packet := p[0]
+		Expect(packet).Should(HaveLen(ipv4HeaderLength + udpHeaderLength + 5))
+		Expect(packet[0]).Should(BeEquivalentTo(0x45))
+		Expect(packet[9]).Should(BeEquivalentTo(protocolUDP))
+		Expect(packet[12:16]).Should(Equal([]byte{192, 0, 2, 1}))
+		Expect(packet[16:20]).Should(Equal([]byte{192, 0, 2, 53}))
+		Expect(sum(0, packet[:ipv4HeaderLength])).Should(BeEquivalentTo(0xffff))
+
+		udp := packet[ipv4HeaderLength:]
+		Expect(binary.BigEndian.Uint16(udp[0:])).Should(BeEquivalentTo(40000))
+		Expect(binary.BigEndian.Uint16(udp[2:])).Should(BeEquivalentTo(53))
+		Expect(binary.BigEndian.Uint16(udp[4:])).Should(BeEquivalentTo(udpHeaderLength + 5))
+		Expect(transportChecksum(src.Addr(), dst.Addr(), protocolUDP, udp)).Should(BeZero())
+		Expect(string(udp[udpHeaderLength:])).Should(Equal("query"))
+	})
+
+	It("should write TCP segments with IPv6 header and continuous sequence numbers", func() {
+		client := netip.MustParseAddrPort("[2001:db8::1]:40000")
+		server := netip.MustParseAddrPort("[2001:db8::53]:53")
+
+		Expect(w.WriteTCP(ts, client, server, []byte("query"))).Should(Succeed())
+		Expect(w.WriteTCP(ts, server, client, []byte("response"))).Should(Succeed())
+		Expect(w.WriteTCP(ts, client, server, []byte("next"))).Should(Succeed())
+
+		p := packets()
+		Expect(p).Should(HaveLen(3))
+
+		for _, packet := range p {
+			Expect(packet[0] >> 4).Should(BeEquivalentTo(6))
+			Expect(packet[6]).Should(BeEquivalentTo(protocolTCP))
+			Expect(int(binary.BigEndian.Uint16(packet[4:]))).Should(Equal(len(packet) - ipv6HeaderLength))
+		}
+
+		tcp := func(i int) []byte { return p[i][ipv6HeaderLength:] }
+		seq := func(i int) uint32 { return binary.BigEndian.Uint32(tcp(i)[4:]) }
+		ack := func(i int) uint32 { return binary.BigEndian.Uint32(tcp(i)[8:]) }
+
+		Expect(transportChecksum(client.Addr(), server.Addr(), protocolTCP, tcp(0))).Should(BeZero())
+		Expect(transportChecksum(server.Addr(), client.Addr(), protocolTCP, tcp(1))).Should(BeZero())
+		Expect(tcp(0)[13]).Should(BeEquivalentTo(tcpFlagsPSHACK))
+		Expect(string(tcp(0)[tcpHeaderLength:])).Should(Equal("query"))
+
+		Expect(seq(0)).Should(BeEquivalentTo(1))
+		Expect(ack(1)).Should(BeEquivalentTo(1 + len("query")))
+		Expect(seq(1)).Should(BeEquivalentTo(1))
+		Expect(seq(2)).Should(BeEquivalentTo(1 + len("query")))
+		Expect(ack(2)).Should(BeEquivalentTo(1 + len("response")))
+	})
+
+	It("should reject addresses with different IP versions", func() {
+		err := w.WriteUDP(ts, netip.MustParseAddrPort("192.0.2.1:1"), netip.MustParseAddrPort("[::1]:53"), nil)
+		Expect(err).Should(MatchError(errMixedVersions))
+	})
+	It("should reject payloads which exceed the length fields", func() {
+		v4src, v4dst := netip.MustParseAddrPort("192.0.2.1:40000"), netip.MustParseAddrPort("192.0.2.53:53")
+		v6src, v6dst := netip.MustParseAddrPort("[2001:db8::1]:40000"), netip.MustParseAddrPort("[2001:db8::53]:53")
+
+		Expect(w.WriteUDP(ts, v4src, v4dst, make([]byte, maxLength-ipv4HeaderLength-udpHeaderLength))).Should(Succeed())
+		Expect(w.WriteUDP(ts, v4src, v4dst, make([]byte, maxLength-ipv4HeaderLength-udpHeaderLength+1))).
+			Should(MatchError(errPacketTooLarge))
+		Expect(w.WriteUDP(ts, v6src, v6dst, make([]byte, maxLength))).Should(MatchError(errPacketTooLarge))
+		Expect(w.WriteTCP(ts, v4src, v4dst, make([]byte, maxLength))).Should(MatchError(errPacketTooLarge))
+		Expect(w.WriteTCP(ts, v6src, v6dst, make([]byte, maxLength-tcpHeaderLength+1))).
+			Should(MatchError(errPacketTooLarge))
+
+		// only the valid datagram was written
+		Expect(packets()).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,132 @@
+package querylog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// output formats of the query log
+const (
+	// FormatJSON writes one JSON object per line
+	FormatJSON = "json"
+	// FormatCSV writes one comma separated line without header, the answers are separated by "; "
+	FormatCSV = "csv"
+)
+
+// TargetStdout writes the query log to the standard output instead of a file
+const TargetStdout = "stdout"
+
+const answerSeparator = "; "
+
+// Options define the target and the format of the query log
+type Options struct {
+	// Target is a file path or "stdout", the query log is disabled if empty
+	Target string
+	// Format is "json" or "csv"
+	Format string
+	// MaxSize is the size in megabytes after which the file is rotated
+	MaxSize int
+	// MaxBackups is the number of rotated files to keep
+	MaxBackups int
+}
+
+// Query is one handled request
+type Query struct {
+	Time      time.Time `json:"time"`
+	Client    string    `json:"client"`
+	Transport string    `json:"transport"`
+	QName     string    `json:"qname"`
+	QType     string    `json:"qtype"`
+	// Rule is the name of the matched rule without the "MOKKA_RULE_" prefix, "default" or "default_<TYPE>"
+	// for a default response, empty if neither a rule nor a default response applied
+	Rule    string   `json:"rule"`
+	RCode   string   `json:"rcode"`
+	Answers []string `json:"answers"`
+	// DurationMS is the duration of the request handling in milliseconds
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Logger writes the queries in the configured format, it is safe for concurrent use
+type Logger struct {
+	mu     sync.Mutex
+	out    io.WriteCloser
+	format string
+	csv    *csv.Writer
+}
+
+// New creates a logger for the target, a file target is created on the first query
+func New(o Options) *Logger {
+	var out io.WriteCloser = nopCloser{os.Stdout}
+
+	if o.Target != TargetStdout {
+		out = &lumberjack.Logger{
+			Filename:   o.Target,
+			MaxSize:    o.MaxSize,
+			MaxBackups: o.MaxBackups,
+		}
+	}
+
+	return &Logger{
+		out:    out,
+		format: o.Format,
+		csv:    csv.NewWriter(out),
+	}
+}
+
+// Log writes the query as one line
+func (l *Logger) Log(e Query) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format == FormatCSV {
+		if err := l.csv.Write(e.record()); err != nil {
+			return err
+		}
+
+		l.csv.Flush()
+
+		return l.csv.Error()
+	}
+
+	return json.NewEncoder(l.out).Encode(e)
+}
+
+// Close closes the file of the query log
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.out.Close()
+}
+
+// record returns the CSV fields of the query
+func (e Query) record() []string {
+	return []string{
+		e.Time.Format(time.RFC3339Nano),
+		e.Client,
+		e.Transport,
+		e.QName,
+		e.QType,
+		e.Rule,
+		e.RCode,
+		strings.Join(e.Answers, answerSeparator),
+		strconv.FormatFloat(e.DurationMS, 'f', -1, 64),
+	}
+}
+
+// nopCloser doesn't close the standard output
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
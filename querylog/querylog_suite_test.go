@@ -0,0 +1,13 @@
+package querylog
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestQueryLog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Query log Suite")
+}
@@ -0,0 +1,73 @@
+package querylog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	var (
+		file  string
+		entry Query
+	)
+
+	BeforeEach(func() {
+		file = filepath.Join(GinkgoT().TempDir(), "queries.log")
+		entry = Query{
+			Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Client:     "192.0.2.1",
+			Transport:  "udp",
+			QName:      "example.com.",
+			QType:      "A",
+			Rule:       "1",
+			RCode:      "NOERROR",
+			Answers:    []string{"example.com. 300 IN A 192.0.2.10", "example.com. 300 IN A 192.0.2.11"},
+			DurationMS: 1.5,
+		}
+	})
+
+	It("should write JSON lines", func() {
+		l := New(Options{Target: file, Format: FormatJSON})
+
+		Expect(l.Log(entry)).Should(Succeed())
+		Expect(l.Log(entry)).Should(Succeed())
+		Expect(l.Close()).Should(Succeed())
+
+		content, err := os.ReadFile(file)
+		Expect(err).Should(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		Expect(lines).Should(HaveLen(2))
+
+		var logged Query
+		Expect(json.Unmarshal([]byte(lines[0]), &logged)).Should(Succeed())
+		Expect(logged).Should(Equal(entry))
+		Expect(lines[0]).Should(ContainSubstring(`"duration_ms":1.5`))
+	})
+
+	It("should write CSV lines", func() {
+		l := New(Options{Target: file, Format: FormatCSV})
+
+		Expect(l.Log(entry)).Should(Succeed())
+		Expect(l.Close()).Should(Succeed())
+
+		f, err := os.Open(file)
+		Expect(err).Should(Succeed())
+
+		defer f.Close()
+
+		records, err := csv.NewReader(f).ReadAll()
+		Expect(err).Should(Succeed())
+		Expect(records).Should(Equal([][]string{{
+			"2024-01-02T03:04:05Z", "192.0.2.1", "udp", "example.com.", "A", "1", "NOERROR",
+			"example.com. 300 IN A 192.0.2.10; example.com. 300 IN A 192.0.2.11", "1.5",
+		}}))
+	})
+})
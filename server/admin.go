@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// adminServer serves the admin HTTP API
+type adminServer struct {
+	server *http.Server
+}
+
+func (s *Server) createAdminServer(address string) *adminServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+notifyPath, s.onNotifyJournal)
+	mux.HandleFunc("DELETE "+notifyPath, s.onNotifyJournalClear)
+	mux.HandleFunc("POST "+notifySendPath, s.onNotifySend)
+	mux.HandleFunc("GET "+dnssecPath, s.onDNSSECKeys)
+	mux.Handle("GET "+metricsPath, s.metrics.handler())
+	mux.HandleFunc("GET "+coveragePath, s.onCoverage)
+	mux.HandleFunc("DELETE "+coveragePath, s.onCoverageClear)
+
+	return &adminServer{
+		server: &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: readTimeout,
+		},
+	}
+}
+
+// ListenAndServe serves requests until Shutdown is called
+func (a *adminServer) ListenAndServe() error {
+	ln, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Admin server is up and running on: '%s'", a.server.Addr)
+
+	if err := a.server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops the server gracefully
+func (a *adminServer) Shutdown() error {
+	return a.server.Shutdown(context.Background())
+}
+
+func (a *adminServer) String() string {
+	return "http://" + a.server.Addr
+}
+
+// writeJSON writes the value as JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("can't write response: ", err)
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	coveragePath = "/coverage"
+	// maxUnmatched is the number of distinct unmatched questions which are kept
+	maxUnmatched = 1000
+)
+
+// unmatchedQuestion is a question which didn't match any rule
+type unmatchedQuestion struct {
+	Name   string    `json:"qname"`
+	Type   string    `json:"qtype"`
+	Opcode string    `json:"opcode"`
+	Count  uint64    `json:"count"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+// coverageReport shows which rules were used and which questions fell through
+type coverageReport struct {
+	// RuleHits contains the number of matches of each rule
+	RuleHits map[string]uint64 `json:"rule_hits"`
+	// UnusedRules are the names of the rules which never matched
+	UnusedRules []string             `json:"unused_rules"`
+	Unmatched   []*unmatchedQuestion `json:"unmatched"`
+	// OmittedUnmatched counts the unmatched questions which were not kept, because the list was full
+	OmittedUnmatched uint64 `json:"omitted_unmatched"`
+}
+
+// coverage counts the rule matches and the questions without matching rule
+type coverage struct {
+	mu        sync.Mutex
+	rules     []string
+	hits      map[string]uint64
+	unmatched map[string]*unmatchedQuestion
+	omitted   uint64
+}
+
+func newCoverage(rules []config.Rule) *coverage {
+	c := &coverage{}
+
+	for _, r := range rules {
+		c.rules = append(c.rules, r.Name)
+	}
+
+	c.clear()
+
+	return c
+}
+
+// hit counts a match of the rule
+func (c *coverage) hit(rule *config.Rule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits[rule.Name]++
+}
+
+// miss records a question without matching rule
+func (c *coverage) miss(question dns.Question, opcode int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	q := &unmatchedQuestion{
+		Name:   strings.ToLower(question.Name),
+		Type:   dns.Type(question.Qtype).String(),
+		Opcode: dns.OpcodeToString[opcode],
+	}
+	key := q.Name + "/" + q.Type + "/" + q.Opcode
+
+	if existing, ok := c.unmatched[key]; ok {
+		existing.Count++
+		existing.Last = now
+
+		return
+	}
+
+	if len(c.unmatched) >= maxUnmatched {
+		c.omitted++
+
+		return
+	}
+
+	q.Count, q.First, q.Last = 1, now, now
+	c.unmatched[key] = q
+}
+
+// report returns the rule hits, the unused rules in configuration order and the unmatched questions,
+// the most frequent first
+func (c *coverage) report() coverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := coverageReport{
+		RuleHits:         make(map[string]uint64, len(c.hits)),
+		UnusedRules:      []string{},
+		Unmatched:        make([]*unmatchedQuestion, 0, len(c.unmatched)),
+		OmittedUnmatched: c.omitted,
+	}
+
+	for _, name := range c.rules {
+		if c.hits[name] == 0 {
+			r.UnusedRules = append(r.UnusedRules, name)
+		} else {
+			r.RuleHits[name] = c.hits[name]
+		}
+	}
+
+	for _, q := range c.unmatched {
+		copied := *q
+		r.Unmatched = append(r.Unmatched, &copied)
+	}
+
+	slices.SortFunc(r.Unmatched, func(a, b *unmatchedQuestion) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type))
+	})
+
+	return r
+}
+
+func (c *coverage) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits = make(map[string]uint64)
+	c.unmatched = make(map[string]*unmatchedQuestion)
+	c.omitted = 0
+}
+
+// logSummary logs the unmatched questions and the unused rules, it is called on shutdown
+func (c *coverage) logSummary() {
+	r := c.report()
+
+	if len(r.Unmatched) == 0 && len(r.UnusedRules) == 0 {
+		log.Info("all rules were used and all questions matched a rule")
+
+		return
+	}
+
+	if len(r.Unmatched) > 0 {
+		questions := make([]string, 0, len(r.Unmatched))
+
+		for _, q := range r.Unmatched {
+			questions = append(questions, fmt.Sprintf("%s %s %s (%d)", q.Opcode, q.Type, q.Name, q.Count))
+		}
+
+		if r.OmittedUnmatched > 0 {
+			questions = append(questions, fmt.Sprintf("%d more", r.OmittedUnmatched))
+		}
+
+		log.Warnf("%d questions didn't match any rule: %s", len(r.Unmatched), strings.Join(questions, ", "))
+	}
+
+	if len(r.UnusedRules) > 0 {
+		log.Warnf("%d rules were never used: %s", len(r.UnusedRules), strings.Join(r.UnusedRules, ", "))
+	}
+}
+
+// onCoverage returns the rule hits, the unused rules and the unmatched questions
+func (s *Server) onCoverage(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.coverage.report())
+}
+
+// onCoverageClear resets the rule hits and the unmatched questions, for example between two test suites
+func (s *Server) onCoverageClear(w http.ResponseWriter, _ *http.Request) {
+	s.coverage.clear()
+
+	w.WriteHeader(http.StatusNoContent)
+}
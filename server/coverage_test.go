@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coverage", func() {
+	report := func() coverageReport {
+		resp, err := http.Get(adminURL + coveragePath)
+		Expect(err).Should(Succeed())
+
+		defer resp.Body.Close()
+
+		var r coverageReport
+		Expect(json.NewDecoder(resp.Body).Decode(&r)).Should(Succeed())
+
+		return r
+	}
+
+	query := func(name string, qType uint16) {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qType)
+
+		_, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+	}
+
+	It("should report rule hits, unused rules and unmatched questions via admin API", func() {
+		query("exact.test.", dns.TypeA)
+		query("Missed.Test.", dns.TypeTXT)
+		query("missed.test.", dns.TypeTXT)
+
+		r := report()
+		Expect(r.RuleHits).Should(HaveKeyWithValue("9", BeNumerically(">=", 1)))
+		Expect(r.UnusedRules).ShouldNot(ContainElement("9"))
+		Expect(r.Unmatched).Should(ContainElement(And(
+			HaveField("Name", "missed.test."),
+			HaveField("Type", "TXT"),
+			HaveField("Opcode", "QUERY"),
+			HaveField("Count", BeEquivalentTo(2)),
+		)))
+	})
+
+	It("should reset the report via admin API", func() {
+		query("missed.test.", dns.TypeTXT)
+
+		req, err := http.NewRequest(http.MethodDelete, adminURL+coveragePath, nil)
+		Expect(err).Should(Succeed())
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).Should(Succeed())
+		resp.Body.Close()
+		Expect(resp.StatusCode).Should(Equal(http.StatusNoContent))
+
+		r := report()
+		Expect(r.RuleHits).Should(BeEmpty())
+		Expect(r.Unmatched).Should(BeEmpty())
+		Expect(r.UnusedRules).Should(ContainElements("1", "9", "H5"))
+	})
+})
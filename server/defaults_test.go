@@ -0,0 +1,72 @@
+package server
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Default response", func() {
+	exchange := func(msg *dns.Msg, network string) (*dns.Msg, error) {
+		client := &dns.Client{Net: network, Timeout: 200 * time.Millisecond}
+		resp, _, err := client.Exchange(msg, "127.0.0.1"+address)
+
+		return resp, err
+	}
+
+	query := func(name string, qType uint16) (*dns.Msg, error) {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qType)
+
+		return exchange(msg, "udp")
+	}
+
+	It("should answer unmatched questions with the default response of the type", func() {
+		resp, err := query("_sip._udp.default.test.", dns.TypeSRV)
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeRefused))
+	})
+
+	It("should answer unmatched questions without default response of the type with NXDOMAIN", func() {
+		resp, err := query("default.test.", dns.TypeTXT)
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+	})
+
+	It("should drop unmatched questions with DROP() default response", func() {
+		_, err := query("default.test.", dns.TypeNAPTR)
+		Expect(err).Should(MatchError(ContainSubstring("timeout")))
+	})
+
+	It("should drop requests of rules with DROP()", func() {
+		_, err := query("drop.test.", dns.TypeA)
+		Expect(err).Should(MatchError(ContainSubstring("timeout")))
+	})
+	It("should drop updates of rules with DROP()", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("drop.test.")
+		msg.Insert([]dns.RR{newRR("www.drop.test. 300 IN A 192.0.2.1")})
+
+		_, err := exchange(msg, "udp")
+		Expect(err).Should(MatchError(ContainSubstring("timeout")))
+	})
+
+	It("should drop NOTIFY messages of rules with DROP() without recording them", func() {
+		msg := new(dns.Msg)
+		msg.SetNotify("drop.test.")
+
+		_, err := exchange(msg, "udp")
+		Expect(err).Should(MatchError(ContainSubstring("timeout")))
+		Expect(notifyJournalEntries()).ShouldNot(ContainElement(HaveField("Zone", "drop.test.")))
+	})
+
+	It("should drop zone transfers of rules with DROP()", func() {
+		msg := new(dns.Msg)
+		msg.SetAxfr("drop.test.")
+
+		_, err := exchange(msg, "tcp")
+		Expect(err).Should(MatchError(ContainSubstring("timeout")))
+	})
+})
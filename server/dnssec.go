@@ -0,0 +1,247 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/0xERR0R/dns-mokka/dnssec"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dnssecPath = "/dnssec"
+	// negativeTTL is the TTL of the synthesized SOA record and the NSEC records of negative answers from rules
+	negativeTTL = 60
+)
+
+// createSigner loads or generates the keys of the signed zones, the signer has no zones if signing is disabled
+func createSigner(cfg config.DNSSECConfig) (*dnssec.Signer, error) {
+	keys := make([]*dnssec.ZoneKeys, 0, len(cfg.Zones))
+
+	for _, z := range cfg.Zones {
+		var (
+			k   *dnssec.ZoneKeys
+			err error
+		)
+
+		if cfg.KeyDir != "" {
+			k, err = dnssec.LoadKeys(cfg.KeyDir, z, cfg.Algorithm)
+		} else {
+			k, err = dnssec.GenerateKeys(z, cfg.Algorithm)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("DNSSEC signing of zone '%s' enabled, DS record: %s", z, k.DS())
+
+		keys = append(keys, k)
+	}
+
+	return dnssec.NewSigner(keys...), nil
+}
+
+// dnssecOK checks if the request has the DNSSEC OK bit (RFC 3225)
+func dnssecOK(request *dns.Msg) bool {
+	edns := request.IsEdns0()
+
+	return edns != nil && edns.Do()
+}
+
+// keyRecords answers DNSKEY and DS queries for the apex of a signed zone with its keys
+func (s *Server) keyRecords(question dns.Question) (resolution, bool) {
+	keys := s.signer.Keys(question.Name)
+	if keys == nil {
+		return resolution{}, false
+	}
+
+	res := resolution{rCode: dns.RcodeSuccess, authoritative: true}
+
+	switch question.Qtype {
+	case dns.TypeDNSKEY:
+		res.answers = keys.DNSKEYs()
+	case dns.TypeDS:
+		res.answers = []dns.RR{keys.DS()}
+	case dns.TypeNSEC3PARAM:
+		if s.cfg.DNSSEC.Denial.Mode != dnssec.DenialNSEC3 {
+			return resolution{}, false
+		}
+
+		res.answers = []dns.RR{s.cfg.DNSSEC.Denial.NSEC3PARAM(keys.Zone)}
+	default:
+		return resolution{}, false
+	}
+
+	return res, true
+}
+
+// apexTypes returns the types of the records at the apex of a signed zone which are created by mokka
+func (s *Server) apexTypes() []uint16 {
+	if s.cfg.DNSSEC.Denial.Mode == dnssec.DenialNSEC3 {
+		return []uint16{dns.TypeDNSKEY, dns.TypeNSEC3PARAM}
+	}
+
+	return []uint16{dns.TypeDNSKEY}
+}
+
+// createRuleNames collects the names and record types of the rules with exact matcher in the signed zones
+func (s *Server) createRuleNames() map[string]dnssec.Names {
+	result := make(map[string]dnssec.Names, len(s.cfg.DNSSEC.Zones))
+
+	for _, z := range s.cfg.DNSSEC.Zones {
+		names := dnssec.Names{}
+		names.Add(z, z, s.apexTypes()...)
+
+		result[z] = names
+	}
+
+	for i := range s.cfg.Rules {
+		r := &s.cfg.Rules[i]
+
+		name := r.ExactName()
+		if name == "" || r.RecordTypes == nil || r.Opcode != dns.OpcodeQuery {
+			continue
+		}
+
+		if keys := s.signer.Zone(name); keys != nil {
+			result[keys.Zone].Add(keys.Zone, name, r.RecordTypes...)
+		}
+	}
+
+	return result
+}
+
+// deny adds the SOA record and the NSEC or NSEC3 records which prove a negative answer in a signed zone
+// if the request has the DO bit. The names of a zone file are used for answers of its zone, the names
+// of the rules otherwise. Rules without SOA record get a synthesized SOA record.
+func (s *Server) deny(question dns.Question, res resolution, ctx *requestContext) resolution {
+	if !ctx.dnssecOK || !isNegative(res) {
+		return res
+	}
+
+	keys := s.signer.Zone(question.Name)
+	if keys == nil {
+		return res
+	}
+
+	names := s.ruleNames[keys.Zone]
+
+	if res.zone != nil && res.zone.Origin == keys.Zone {
+		names = res.zone.Names()
+		names.Add(keys.Zone, keys.Zone, s.apexTypes()...)
+	}
+
+	soa := findSOA(res.ns)
+	if soa == nil {
+		soa = syntheticSOA(keys.Zone)
+		res.ns = append(res.ns, soa)
+	}
+
+	records, rCode := s.cfg.DNSSEC.Denial.Deny(keys.Zone, names, question.Name, res.rCode, soa.Hdr.Ttl)
+
+	res.rCode = rCode
+	res.authoritative = true
+	res.ns = append(res.ns, records...)
+
+	return res
+}
+
+// isNegative checks if the resolution is NXDOMAIN or NODATA, referrals are not negative
+func isNegative(res resolution) bool {
+	if res.rCode == dns.RcodeNameError {
+		return true
+	}
+
+	if res.rCode != dns.RcodeSuccess || len(res.answers) > 0 {
+		return false
+	}
+
+	for _, rr := range res.ns {
+		if rr.Header().Rrtype == dns.TypeNS {
+			return false
+		}
+	}
+
+	return true
+}
+
+func findSOA(records []dns.RR) *dns.SOA {
+	for _, rr := range records {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+
+	return nil
+}
+
+// syntheticSOA returns the SOA record of negative answers from rules in a signed zone
+func syntheticSOA(zone string) *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: negativeTTL},
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  1,
+		Refresh: negativeTTL,
+		Retry:   negativeTTL,
+		Expire:  negativeTTL,
+		Minttl:  negativeTTL,
+	}
+}
+
+// sign adds the signatures of the records in signed zones if the request has the DO bit.
+// NS and glue records of a referral are not authoritative, only its DS records are signed.
+func (s *Server) sign(res resolution, mode string, ctx *requestContext) resolution {
+	if !ctx.dnssecOK {
+		return res
+	}
+
+	referral := !res.authoritative && len(res.answers) == 0 && len(res.ns) > 0
+
+	var err error
+
+	if res.answers, err = s.signer.SignRecords(res.answers, mode); err == nil {
+		if referral {
+			res.ns, err = s.signer.SignRecords(res.ns, mode, dns.TypeDS)
+		} else if res.ns, err = s.signer.SignRecords(res.ns, mode); err == nil {
+			res.extra, err = s.signer.SignRecords(res.extra, mode)
+		}
+	}
+
+	if err != nil {
+		log.Error("can't sign response: ", err)
+
+		return resolution{rCode: dns.RcodeServerFailure}
+	}
+
+	return res
+}
+
+// zoneKeys contains the public keys of a signed zone, for example to configure a trust anchor
+type zoneKeys struct {
+	Zone   string   `json:"zone"`
+	DNSKEY []string `json:"dnskey"`
+	DS     string   `json:"ds"`
+}
+
+// onDNSSECKeys returns the DNSKEY and DS records of the signed zones
+func (s *Server) onDNSSECKeys(w http.ResponseWriter, _ *http.Request) {
+	result := make([]zoneKeys, 0)
+
+	for _, k := range s.signer.Zones() {
+		result = append(result, zoneKeys{
+			Zone:   k.Zone,
+			DNSKEY: []string{k.KSK.DNSKEY.String(), k.ZSK.DNSKEY.String()},
+			DS:     k.DS().String(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Zone < result[j].Zone
+	})
+
+	writeJSON(w, result)
+}
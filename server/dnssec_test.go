@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DNSSEC", func() {
+	// queryDO sends the query with the DNSSEC OK bit
+	queryDO := func(name string, qType uint16) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qType)
+		msg.SetEdns0(4096, true)
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		return resp
+	}
+
+	split := func(records []dns.RR) (rrset []dns.RR, sigs []*dns.RRSIG) {
+		for _, rr := range records {
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				sigs = append(sigs, sig)
+			} else {
+				rrset = append(rrset, rr)
+			}
+		}
+
+		return rrset, sigs
+	}
+
+	// zsk returns the zone signing key of the zone, the DNSKEY RRset is verified with the KSK
+	zsk := func(zone string) *dns.DNSKEY {
+		keys, sigs := split(queryDO(zone, dns.TypeDNSKEY).Answer)
+		Expect(keys).Should(HaveLen(2))
+		Expect(sigs).Should(HaveLen(1))
+
+		for _, k := range keys {
+			if k.(*dns.DNSKEY).KeyTag() == sigs[0].KeyTag {
+				Expect(sigs[0].Verify(k.(*dns.DNSKEY), keys)).Should(Succeed())
+			}
+		}
+
+		for _, k := range keys {
+			if k.(*dns.DNSKEY).Flags == dns.ZONE {
+				return k.(*dns.DNSKEY)
+			}
+		}
+
+		Fail("zone has no ZSK")
+
+		return nil
+	}
+
+	It("should sign the answer RRset if the DO bit is set", func() {
+		resp := queryDO("valid.dnssec.test.", dns.TypeA)
+
+		Expect(resp.IsEdns0()).ShouldNot(BeNil())
+		Expect(resp.IsEdns0().Do()).Should(BeTrue())
+
+		rrset, sigs := split(resp.Answer)
+		Expect(rrset).Should(HaveLen(2))
+		Expect(sigs).Should(HaveLen(1))
+		Expect(sigs[0].SignerName).Should(Equal("dnssec.test."))
+		Expect(sigs[0].Verify(zsk("dnssec.test."), rrset)).Should(Succeed())
+		Expect(sigs[0].ValidityPeriod(time.Now())).Should(BeTrue())
+	})
+
+	It("should not sign the answer without DO bit", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("valid.dnssec.test.", dns.TypeA)
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		_, sigs := split(resp.Answer)
+		Expect(sigs).Should(BeEmpty())
+	})
+
+	It("should serve the DS record of the KSK", func() {
+		resp := queryDO("dnssec.test.", dns.TypeDS)
+
+		Expect(resp.Answer).Should(HaveLen(1))
+		ds := resp.Answer[0].(*dns.DS)
+
+		keys, _ := split(queryDO("dnssec.test.", dns.TypeDNSKEY).Answer)
+		Expect(keys[0].(*dns.DNSKEY).ToDS(dns.SHA256).Digest).Should(Equal(ds.Digest))
+	})
+
+	It("should create expired signatures", func() {
+		rrset, sigs := split(queryDO("expired.dnssec.test.", dns.TypeA).Answer)
+
+		Expect(sigs[0].Verify(zsk("dnssec.test."), rrset)).Should(Succeed())
+		Expect(sigs[0].ValidityPeriod(time.Now())).Should(BeFalse())
+		Expect(int64(sigs[0].Expiration)).Should(BeNumerically("<", time.Now().Unix()))
+	})
+
+	It("should create signatures which are not yet valid", func() {
+		rrset, sigs := split(queryDO("notyetvalid.dnssec.test.", dns.TypeA).Answer)
+
+		Expect(sigs[0].Verify(zsk("dnssec.test."), rrset)).Should(Succeed())
+		Expect(int64(sigs[0].Inception)).Should(BeNumerically(">", time.Now().Unix()))
+	})
+
+	It("should create bogus signatures", func() {
+		rrset, sigs := split(queryDO("broken.dnssec.test.", dns.TypeA).Answer)
+
+		Expect(sigs[0].ValidityPeriod(time.Now())).Should(BeTrue())
+		Expect(sigs[0].Verify(zsk("dnssec.test."), rrset)).ShouldNot(Succeed())
+	})
+
+	It("should leave the answer unsigned", func() {
+		rrset, sigs := split(queryDO("plain.dnssec.test.", dns.TypeA).Answer)
+
+		Expect(rrset).Should(HaveLen(1))
+		Expect(sigs).Should(BeEmpty())
+	})
+
+	// verifyAll verifies the signature of each RRset in the records
+	verifyAll := func(records []dns.RR, key *dns.DNSKEY) {
+		rrset, sigs := split(records)
+		Expect(sigs).ShouldNot(BeEmpty())
+
+		for _, sig := range sigs {
+			var covered []dns.RR
+
+			for _, rr := range rrset {
+				if rr.Header().Rrtype == sig.TypeCovered && rr.Header().Name == sig.Hdr.Name {
+					covered = append(covered, rr)
+				}
+			}
+
+			Expect(sig.Verify(key, covered)).Should(Succeed())
+		}
+	}
+
+	nsecRecords := func(records []dns.RR) []*dns.NSEC {
+		var result []*dns.NSEC
+
+		for _, rr := range records {
+			if nsec, ok := rr.(*dns.NSEC); ok {
+				result = append(result, nsec)
+			}
+		}
+
+		return result
+	}
+
+	It("should prove NXDOMAIN of zones with signed SOA and NSEC records", func() {
+		resp := queryDO("absent.mokka.test.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+		Expect(resp.Authoritative).Should(BeTrue())
+
+		// the NSEC record of the apex covers the name and the wildcard
+		nsec := nsecRecords(resp.Ns)
+		Expect(nsec).Should(HaveLen(1))
+		Expect(nsec[0].Hdr.Name).Should(Equal("mokka.test."))
+		Expect(nsec[0].NextDomain).Should(Equal("alias.mokka.test."))
+		verifyAll(resp.Ns, zsk("mokka.test."))
+	})
+
+	It("should prove NODATA of zones with the matching NSEC record", func() {
+		resp := queryDO("www.mokka.test.", dns.TypeAAAA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).Should(BeEmpty())
+
+		nsec := nsecRecords(resp.Ns)
+		Expect(nsec).Should(HaveLen(1))
+		Expect(nsec[0].Hdr.Name).Should(Equal("www.mokka.test."))
+		Expect(nsec[0].TypeBitMap).Should(Equal([]uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}))
+		verifyAll(resp.Ns, zsk("mokka.test."))
+	})
+
+	It("should prove NXDOMAIN of rules with a synthesized SOA record", func() {
+		resp := queryDO("absent.dnssec.test.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+		Expect(resp.Ns[0].Header().Rrtype).Should(Equal(dns.TypeSOA))
+		Expect(resp.Ns[0].Header().Name).Should(Equal("dnssec.test."))
+
+		nsec := nsecRecords(resp.Ns)
+		Expect(nsec).ShouldNot(BeEmpty())
+		Expect(nsec[0].Hdr.Name).Should(Equal("dnssec.test."))
+		Expect(nsec[0].NextDomain).Should(Equal("broken.dnssec.test."))
+		Expect(nsec[0].TypeBitMap).Should(ContainElement(dns.TypeDNSKEY))
+		verifyAll(resp.Ns, zsk("dnssec.test."))
+	})
+
+	It("should not add NSEC records without DO bit", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("absent.dnssec.test.", dns.TypeA)
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Ns).Should(BeEmpty())
+	})
+
+	It("should not sign NS and glue records of a referral", func() {
+		resp := queryDO("www.sub.mokka.test.", dns.TypeA)
+
+		Expect(resp.Authoritative).Should(BeFalse())
+		Expect(resp.Ns).Should(HaveLen(1))
+		Expect(resp.Extra).Should(HaveLen(2))
+	})
+
+	It("should return the keys of the signed zones via admin API", func() {
+		resp, err := http.Get(adminURL + dnssecPath)
+		Expect(err).Should(Succeed())
+
+		defer resp.Body.Close()
+
+		var keys []zoneKeys
+		Expect(json.NewDecoder(resp.Body).Decode(&keys)).Should(Succeed())
+		Expect(keys).Should(HaveLen(2))
+		Expect(keys[0].Zone).Should(Equal("dnssec.test."))
+		Expect(keys[0].DNSKEY).Should(HaveLen(2))
+		Expect(keys[0].DS).Should(ContainSubstring("DS"))
+	})
+})
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	dnstap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// dnstapProtocolDoQ is DNS over QUIC in dnstap.proto, the generated code doesn't know it yet
+	dnstapProtocolDoQ = dnstap.SocketProtocol(7)
+	// dnstapFlushTimeout is the maximal time the frames are kept in the buffer of the socket
+	dnstapFlushTimeout = time.Second
+	// dnstapRetryInterval is the time to wait before the connection to the socket is established again
+	dnstapRetryInterval = time.Second
+	dnstapVersion       = "mokka"
+)
+
+// dnstapWriter writes the handled requests as dnstap messages to a socket or a file
+type dnstapWriter struct {
+	output       dnstap.Output
+	identity     []byte
+	queryType    dnstap.Message_Type
+	responseType dnstap.Message_Type
+}
+
+func newDNStapWriter(cfg config.DNStapConfig) (*dnstapWriter, error) {
+	w := &dnstapWriter{
+		identity:     []byte(cfg.Identity),
+		queryType:    dnstap.Message_AUTH_QUERY,
+		responseType: dnstap.Message_AUTH_RESPONSE,
+	}
+
+	if cfg.Messages == config.DNStapClient {
+		w.queryType, w.responseType = dnstap.Message_CLIENT_QUERY, dnstap.Message_CLIENT_RESPONSE
+	}
+
+	if cfg.Socket != "" {
+		output, err := dnstap.NewFrameStreamSockOutput(&net.UnixAddr{Name: cfg.Socket, Net: "unix"})
+		if err != nil {
+			return nil, err
+		}
+
+		output.SetFlushTimeout(dnstapFlushTimeout)
+		output.SetRetryInterval(dnstapRetryInterval)
+		w.output = output
+	} else {
+		output, err := dnstap.NewFrameStreamOutputFromFilename(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+
+		w.output = output
+	}
+
+	go w.output.RunOutputLoop()
+
+	return w, nil
+}
+
+// write sends the query and the response (if one was written) of the request
+func (w *dnstapWriter) write(request *dns.Msg, ctx *requestContext) {
+	queryTime := ctx.start
+
+	query := w.message(w.queryType, ctx)
+	query.QueryTimeSec, query.QueryTimeNsec = timestamp(queryTime)
+
+	if msg, err := request.Pack(); err == nil {
+		query.QueryMessage = msg
+	}
+
+	w.send(query)
+
+	if ctx.response == nil {
+		return
+	}
+
+	response := w.message(w.responseType, ctx)
+	response.QueryTimeSec, response.QueryTimeNsec = timestamp(queryTime)
+	response.ResponseTimeSec, response.ResponseTimeNsec = timestamp(time.Now())
+
+	if msg, err := ctx.response.Pack(); err == nil {
+		response.ResponseMessage = msg
+	}
+
+	w.send(response)
+}
+
+// message creates a message with the addresses of the client (query) and of the listener (response)
+func (w *dnstapWriter) message(t dnstap.Message_Type, ctx *requestContext) *dnstap.Message {
+	m := &dnstap.Message{
+		Type:           &t,
+		SocketProtocol: dnstapProtocol(ctx.transport).Enum(),
+	}
+
+	clientIP, clientPort := addrIPPort(ctx.remoteAddr)
+	serverIP, serverPort := addrIPPort(ctx.localAddr)
+
+	family := dnstap.SocketFamily_INET6
+	if clientIP.To4() != nil {
+		family = dnstap.SocketFamily_INET
+		clientIP, serverIP = clientIP.To4(), serverIP.To4()
+	}
+
+	m.SocketFamily = &family
+	m.QueryAddress, m.QueryPort = clientIP, clientPort
+	m.ResponseAddress, m.ResponsePort = serverIP, serverPort
+
+	return m
+}
+
+// send writes the frame without blocking the request, the message is dropped if the buffer is full
+func (w *dnstapWriter) send(m *dnstap.Message) {
+	dnstapType := dnstap.Dnstap_MESSAGE
+
+	frame, err := proto.Marshal(&dnstap.Dnstap{
+		Type:     &dnstapType,
+		Identity: w.identity,
+		Version:  []byte(dnstapVersion),
+		Message:  m,
+	})
+	if err != nil {
+		log.Errorf("can't create dnstap message: %v", err)
+
+		return
+	}
+
+	select {
+	case w.output.GetOutputChannel() <- frame:
+	default:
+		log.Warn("dnstap buffer is full, message dropped")
+	}
+}
+
+// close flushes the buffered frames and closes the socket or the file
+func (w *dnstapWriter) close() {
+	w.output.Close()
+}
+
+func dnstapProtocol(transport string) dnstap.SocketProtocol {
+	switch transport {
+	case config.ProtocolTCP:
+		return dnstap.SocketProtocol_TCP
+	case config.ProtocolDoT:
+		return dnstap.SocketProtocol_DOT
+	case config.ProtocolDoH:
+		return dnstap.SocketProtocol_DOH
+	case config.ProtocolDoQ:
+		return dnstapProtocolDoQ
+	}
+
+	return dnstap.SocketProtocol_UDP
+}
+
+// addrIPPort returns the IP address and the port of a network address, nil if unknown
+func addrIPPort(addr net.Addr) (net.IP, *uint32) {
+	if addr == nil {
+		return nil, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, nil
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return net.ParseIP(host), nil
+	}
+
+	result := uint32(p)
+
+	return net.ParseIP(host), &result
+}
+
+func timestamp(t time.Time) (*uint64, *uint32) {
+	sec, nsec := uint64(t.Unix()), uint32(t.Nanosecond())
+
+	return &sec, &nsec
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ = Describe("dnstap", func() {
+	// receive returns the next dnstap messages of the request with the ID
+	receive := func(id uint16) []*dnstap.Message {
+		var result []*dnstap.Message
+
+		Eventually(func() []*dnstap.Message {
+			select {
+			case frame := <-dnstapFrames:
+				var tap dnstap.Dnstap
+				Expect(proto.Unmarshal(frame, &tap)).Should(Succeed())
+				Expect(string(tap.GetIdentity())).Should(Equal("mokka-test"))
+
+				m := tap.GetMessage()
+
+				wire := m.GetQueryMessage()
+				if wire == nil {
+					wire = m.GetResponseMessage()
+				}
+
+				msg := new(dns.Msg)
+				Expect(msg.Unpack(wire)).Should(Succeed())
+
+				if msg.Id == id {
+					result = append(result, m)
+				}
+			default:
+			}
+
+			return result
+		}, 5*time.Second).Should(HaveLen(2))
+
+		return result
+	}
+
+	It("should write the query and the response as AUTH messages", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("multi.test.", dns.TypeMX)
+
+		resp, err := requestServer(msg, "tcp")
+		Expect(err).Should(Succeed())
+
+		messages := receive(msg.Id)
+
+		query, response := messages[0], messages[1]
+		Expect(query.GetType()).Should(Equal(dnstap.Message_AUTH_QUERY))
+		Expect(query.GetSocketProtocol()).Should(Equal(dnstap.SocketProtocol_TCP))
+		Expect(query.GetSocketFamily()).Should(Equal(dnstap.SocketFamily_INET))
+		Expect(query.GetQueryAddress()).Should(BeEquivalentTo([]byte{127, 0, 0, 1}))
+		Expect(query.GetResponsePort()).Should(BeEquivalentTo(55555))
+
+		Expect(response.GetType()).Should(Equal(dnstap.Message_AUTH_RESPONSE))
+		Expect(response.GetQueryTimeSec()).Should(Equal(query.GetQueryTimeSec()))
+		Expect(response.GetResponseTimeSec()).Should(BeNumerically(">=", query.GetQueryTimeSec()))
+
+		answer := new(dns.Msg)
+		Expect(answer.Unpack(response.GetResponseMessage())).Should(Succeed())
+		Expect(answer.Answer).Should(HaveLen(len(resp.Answer)))
+	})
+})
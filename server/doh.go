@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dohPath           = "/dns-query"
+	jsonPath          = "/resolve"
+	dnsMessageType    = "application/dns-message"
+	dnsJSONType       = "application/dns-json"
+	dnsParam          = "dns"
+	nameParam         = "name"
+	typeParam         = "type"
+	maxDoHRequestSize = dns.MaxMsgSize
+	readTimeout       = 10 * time.Second
+)
+
+// dohServer is a DNS-over-HTTPS server
+type dohServer struct {
+	server   *http.Server
+	listener config.Listener
+}
+
+func (s *Server) createDoHServer(l config.Listener) *dohServer {
+	handler := s.handler(l)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(dohPath, func(w http.ResponseWriter, r *http.Request) {
+		onDoHRequest(w, r, handler)
+	})
+	mux.HandleFunc(jsonPath, func(w http.ResponseWriter, r *http.Request) {
+		onJSONRequest(w, r, handler)
+	})
+
+	return &dohServer{
+		server: &http.Server{
+			Addr:              l.Address,
+			Handler:           mux,
+			ReadHeaderTimeout: readTimeout,
+			TLSConfig:         s.tlsConfig,
+		},
+		listener: l,
+	}
+}
+
+// ListenAndServe serves requests until Shutdown is called
+func (d *dohServer) ListenAndServe() error {
+	ln, err := net.Listen(d.listener.Network(), d.listener.Address)
+	if err != nil {
+		return err
+	}
+
+	if d.listener.ProxyProtocol {
+		ln = newProxyListener(ln)
+	}
+
+	log.Infof("DoH server is up and running on: '%s'", d.listener.Address)
+
+	if err := d.server.ServeTLS(ln, "", ""); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops the server gracefully
+func (d *dohServer) Shutdown() error {
+	return d.server.Shutdown(context.Background())
+}
+
+func (d *dohServer) String() string {
+	return d.listener.String()
+}
+
+// onDoHRequest handles RFC 8484 requests (GET with "dns" parameter and POST),
+// requests with "name" parameter are answered in the JSON format
+func onDoHRequest(w http.ResponseWriter, r *http.Request, handler dns.Handler) {
+	if r.Method == http.MethodGet && r.URL.Query().Has(nameParam) {
+		onJSONRequest(w, r, handler)
+
+		return
+	}
+
+	request, err := readDoHRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	response := handleHTTPRequest(r, request, handler)
+	if response == nil {
+		http.Error(w, "no response", http.StatusGatewayTimeout)
+
+		return
+	}
+
+	b, err := response.Pack()
+	if err != nil {
+		log.Error("can't pack response: ", err)
+		http.Error(w, "can't pack response", http.StatusInternalServerError)
+
+		return
+	}
+
+	writeHTTPResponse(w, dnsMessageType, response, b)
+}
+
+func readDoHRequest(r *http.Request) (*dns.Msg, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		param := r.URL.Query().Get(dnsParam)
+		if param == "" {
+			return nil, fmt.Errorf("missing '%s' parameter", dnsParam)
+		}
+
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
+	case http.MethodPost:
+		if ct := r.Header.Get("Content-Type"); ct != dnsMessageType {
+			return nil, fmt.Errorf("unsupported content type '%s'", ct)
+		}
+
+		b, err = io.ReadAll(io.LimitReader(r.Body, maxDoHRequestSize))
+	default:
+		return nil, fmt.Errorf("unsupported method '%s'", r.Method)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't read request: %w", err)
+	}
+
+	request := new(dns.Msg)
+	if err := request.Unpack(b); err != nil {
+		return nil, fmt.Errorf("can't unpack request: %w", err)
+	}
+
+	return request, nil
+}
+
+// jsonResponse is the JSON API format as used by Google and Cloudflare
+type jsonResponse struct {
+	Status   int            `json:"Status"`
+	TC       bool           `json:"TC"`
+	RD       bool           `json:"RD"`
+	RA       bool           `json:"RA"`
+	AD       bool           `json:"AD"`
+	CD       bool           `json:"CD"`
+	Question []jsonQuestion `json:"Question"`
+	Answer   []jsonRecord   `json:"Answer,omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// onJSONRequest handles GET requests with "name" and "type" (optional, default A) parameters
+func onJSONRequest(w http.ResponseWriter, r *http.Request, handler dns.Handler) {
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("unsupported method '%s'", r.Method), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	name := r.URL.Query().Get(nameParam)
+	if name == "" {
+		http.Error(w, fmt.Sprintf("missing '%s' parameter", nameParam), http.StatusBadRequest)
+
+		return
+	}
+
+	qType, err := parseJSONType(r.URL.Query().Get(typeParam))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	request := new(dns.Msg)
+	request.SetQuestion(dns.Fqdn(name), qType)
+
+	response := handleHTTPRequest(r, request, handler)
+	if response == nil {
+		http.Error(w, "no response", http.StatusGatewayTimeout)
+
+		return
+	}
+
+	b, err := json.Marshal(toJSONResponse(response))
+	if err != nil {
+		http.Error(w, "can't create response", http.StatusInternalServerError)
+
+		return
+	}
+
+	writeHTTPResponse(w, dnsJSONType, response, b)
+}
+
+func parseJSONType(in string) (uint16, error) {
+	if in == "" {
+		return dns.TypeA, nil
+	}
+
+	if t, found := dns.StringToType[strings.ToUpper(in)]; found {
+		return t, nil
+	}
+
+	t, err := strconv.ParseUint(in, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown type '%s'", in)
+	}
+
+	return uint16(t), nil
+}
+
+func toJSONResponse(msg *dns.Msg) jsonResponse {
+	res := jsonResponse{
+		Status: msg.Rcode,
+		TC:     msg.Truncated,
+		RD:     msg.RecursionDesired,
+		RA:     msg.RecursionAvailable,
+		AD:     msg.AuthenticatedData,
+		CD:     msg.CheckingDisabled,
+	}
+
+	for _, q := range msg.Question {
+		res.Question = append(res.Question, jsonQuestion{Name: q.Name, Type: q.Qtype})
+	}
+
+	for _, rr := range msg.Answer {
+		hdr := rr.Header()
+		res.Answer = append(res.Answer, jsonRecord{
+			Name: hdr.Name,
+			Type: hdr.Rrtype,
+			TTL:  hdr.Ttl,
+			Data: strings.TrimPrefix(rr.String(), hdr.String()),
+		})
+	}
+
+	return res
+}
+
+// handleHTTPRequest passes the request to the DNS handler, returns nil if no response was written
+func handleHTTPRequest(r *http.Request, request *dns.Msg, handler dns.Handler) *dns.Msg {
+	localAddr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+
+	// a typed nil pointer must not be passed as address
+	var remoteAddr net.Addr
+
+	if tcpAddr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err != nil {
+		log.Warnf("can't parse remote address '%s': %v", r.RemoteAddr, err)
+	} else {
+		remoteAddr = tcpAddr
+	}
+
+	writer := newBufferedResponseWriter(localAddr, remoteAddr)
+	handler.ServeDNS(writer, request)
+
+	return writer.response
+}
+
+// writeHTTPResponse writes the response, the cache lifetime is the minimal TTL of the answer
+func writeHTTPResponse(w http.ResponseWriter, contentType string, response *dns.Msg, b []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", minTTL(response)))
+
+	if _, err := w.Write(b); err != nil {
+		log.Error("can't write response: ", err)
+	}
+}
+
+func minTTL(msg *dns.Msg) uint32 {
+	if len(msg.Answer) == 0 {
+		return 0
+	}
+
+	ttl := uint32(math.MaxUint32)
+
+	for _, rr := range msg.Answer {
+		ttl = min(ttl, rr.Header().Ttl)
+	}
+
+	return ttl
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DoH", func() {
+	When("GET request with dns parameter is performed", func() {
+		It("should return the DNS message", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion("google.de.", dns.TypeA)
+			msg.Id = 0
+
+			b, err := msg.Pack()
+			Expect(err).Should(Succeed())
+
+			resp, err := httpClient().Get("https://" + dohAddress + "/dns-query?dns=" + base64.RawURLEncoding.EncodeToString(b))
+			Expect(err).Should(Succeed())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).Should(Equal("application/dns-message"))
+			Expect(resp.Header.Get("Cache-Control")).Should(Equal("max-age=123"))
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).Should(Succeed())
+
+			response := new(dns.Msg)
+			Expect(response.Unpack(body)).Should(Succeed())
+			Expect(response.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
+		})
+
+		It("should fail without dns parameter", func() {
+			resp, err := httpClient().Get("https://" + dohAddress + "/dns-query")
+			Expect(err).Should(Succeed())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+	})
+
+	When("POST request has wrong content type", func() {
+		It("should fail", func() {
+			resp, err := httpClient().Post("https://"+dohAddress+"/dns-query", "text/plain", http.NoBody)
+			Expect(err).Should(Succeed())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+	})
+
+	When("JSON request is performed", func() {
+		for _, path := range []string{"/dns-query", "/resolve"} {
+			path := path
+
+			It("should return the JSON response on "+path, func() {
+				resp, err := httpClient().Get("https://" + dohAddress + path + "?name=google.de&type=A")
+				Expect(err).Should(Succeed())
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+				Expect(resp.Header.Get("Content-Type")).Should(Equal("application/dns-json"))
+
+				var res jsonResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&res)).Should(Succeed())
+				Expect(res.Status).Should(Equal(dns.RcodeSuccess))
+				Expect(res.Question).Should(Equal([]jsonQuestion{{Name: "google.de.", Type: dns.TypeA}}))
+				Expect(res.Answer).Should(Equal([]jsonRecord{{Name: "google.de.", Type: dns.TypeA, TTL: 123, Data: "1.2.3.4"}}))
+			})
+		}
+
+		It("should return NXDOMAIN status", func() {
+			resp, err := httpClient().Get("https://" + dohAddress + "/resolve?name=unmatched.com&type=1")
+			Expect(err).Should(Succeed())
+			defer resp.Body.Close()
+
+			var res jsonResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&res)).Should(Succeed())
+			Expect(res.Status).Should(Equal(dns.RcodeNameError))
+			Expect(res.Answer).Should(BeEmpty())
+		})
+
+		It("should fail on unknown type", func() {
+			resp, err := httpClient().Get("https://" + dohAddress + "/resolve?name=google.de&type=FOO")
+			Expect(err).Should(Succeed())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+	})
+	When("remote address of the request is invalid", func() {
+		It("should answer without client address", func() {
+			r := httptest.NewRequest(http.MethodGet, "/dns-query", nil)
+			r.RemoteAddr = "invalid"
+
+			msg := new(dns.Msg)
+			msg.SetQuestion("client.test.", dns.TypeA)
+
+			handler := sut.handler(config.Listener{Protocol: config.ProtocolDoH})
+
+			var response *dns.Msg
+
+			Expect(func() { response = handleHTTPRequest(r, msg, handler) }).ShouldNot(Panic())
+			Expect(response).ShouldNot(BeNil())
+			Expect(response.Rcode).Should(Equal(dns.RcodeNameError))
+		})
+	})
+})
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	doqALPN = "doq"
+
+	// DoQ error codes, see RFC 9250 section 4.3
+	doqNoError       quic.ApplicationErrorCode = 0x0
+	doqInternalError quic.ApplicationErrorCode = 0x1
+	doqProtocolError quic.ApplicationErrorCode = 0x2
+
+	doqLengthSize = 2
+
+	// doqReadTimeout limits the time to receive the query of a stream, like the read timeout of TCP and DoT
+	doqReadTimeout = 2 * time.Second
+)
+
+// doqServer is a DNS-over-QUIC (RFC 9250) server, each query is sent on its own stream
+type doqServer struct {
+	listener  config.Listener
+	tlsConfig *tls.Config
+	handler   dns.Handler
+
+	mu         sync.Mutex
+	packetConn net.PacketConn
+	quic       *quic.Listener
+	// closed is set by Shutdown, a listener which is bound afterwards is closed immediately
+	closed bool
+}
+
+func createDoQServer(l config.Listener, tlsConfig *tls.Config, handler dns.Handler) *doqServer {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{doqALPN}
+
+	return &doqServer{
+		listener:  l,
+		tlsConfig: tlsConfig,
+		handler:   handler,
+	}
+}
+
+// ListenAndServe accepts connections until Shutdown is called, it returns immediately if Shutdown was called before
+func (d *doqServer) ListenAndServe() error {
+	packetConn, err := net.ListenPacket(d.listener.Network(), d.listener.Address)
+	if err != nil {
+		return err
+	}
+
+	listener, err := quic.Listen(packetConn, d.tlsConfig, &quic.Config{})
+	if err != nil {
+		return errors.Join(err, packetConn.Close())
+	}
+
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+
+		return errors.Join(listener.Close(), packetConn.Close())
+	}
+
+	d.packetConn = packetConn
+	d.quic = listener
+	d.mu.Unlock()
+
+	log.Infof("DoQ server is up and running on: '%s'", d.listener.Address)
+
+	for {
+		conn, err := listener.Accept(context.Background())
+		if err != nil {
+			if errors.Is(err, quic.ErrServerClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		go d.serveConn(conn)
+	}
+}
+
+// Shutdown closes the listener and all connections
+func (d *doqServer) Shutdown() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+
+	if d.quic == nil {
+		return nil
+	}
+
+	return errors.Join(d.quic.Close(), d.packetConn.Close())
+}
+
+func (d *doqServer) String() string {
+	return d.listener.String()
+}
+
+func (d *doqServer) serveConn(conn *quic.Conn) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+
+		go d.serveStream(conn, stream)
+	}
+}
+
+func (d *doqServer) serveStream(conn *quic.Conn, stream *quic.Stream) {
+	if err := stream.SetReadDeadline(time.Now().Add(doqReadTimeout)); err != nil {
+		log.Debug("can't set DoQ read deadline: ", err)
+	}
+
+	request, err := readDoQMessage(stream)
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		// only the stream is cancelled, the other streams of the connection continue
+		log.Debug("DoQ request wasn't received in time: ", err)
+		stream.CancelRead(quic.StreamErrorCode(doqNoError))
+		stream.CancelWrite(quic.StreamErrorCode(doqNoError))
+
+		return
+	}
+
+	if err != nil {
+		log.Debug("can't read DoQ request: ", err)
+		_ = conn.CloseWithError(doqProtocolError, err.Error())
+
+		return
+	}
+
+	// the message ID must be 0, see RFC 9250 section 4.2.1
+	if request.Id != 0 {
+		_ = conn.CloseWithError(doqProtocolError, "message ID must be 0")
+
+		return
+	}
+
+	writer := newBufferedResponseWriter(conn.LocalAddr(), conn.RemoteAddr())
+	d.handler.ServeDNS(writer, request)
+
+	if writer.response == nil {
+		stream.CancelWrite(quic.StreamErrorCode(doqNoError))
+
+		return
+	}
+
+	if err := writeDoQMessage(stream, writer.response); err != nil {
+		log.Error("can't write DoQ response: ", err)
+		_ = conn.CloseWithError(doqInternalError, "can't write response")
+	}
+}
+
+// readDoQMessage reads a message with 2 byte length prefix, the client must close the stream after the query
+func readDoQMessage(r io.Reader) (*dns.Msg, error) {
+	b, err := io.ReadAll(io.LimitReader(r, doqLengthSize+dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < doqLengthSize || int(binary.BigEndian.Uint16(b)) != len(b)-doqLengthSize {
+		return nil, errors.New("invalid message length")
+	}
+
+	msg := new(dns.Msg)
+	if err := msg.Unpack(b[doqLengthSize:]); err != nil {
+		return nil, fmt.Errorf("can't unpack message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// writeDoQMessage writes a message with 2 byte length prefix and closes the stream
+func writeDoQMessage(w io.WriteCloser, msg *dns.Msg) error {
+	b, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, doqLengthSize, doqLengthSize+len(b))
+	binary.BigEndian.PutUint16(buf, uint16(len(b)))
+
+	if _, err := w.Write(append(buf, b...)); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/quic-go/quic-go"
+)
+
+var _ = Describe("DoQ", func() {
+	When("server is shut down before it is started", func() {
+		It("should not start the listener", func() {
+			const shutdownAddress = "127.0.0.1:55562"
+
+			l := config.Listener{Protocol: config.ProtocolDoQ, Address: shutdownAddress}
+			d := createDoQServer(l, sut.tlsConfig, sut.handler(l))
+
+			Expect(d.Shutdown()).Should(Succeed())
+			Expect(d.ListenAndServe()).Should(Succeed())
+
+			// the port is free again
+			pc, err := net.ListenPacket("udp", shutdownAddress)
+			Expect(err).Should(Succeed())
+			Expect(pc.Close()).Should(Succeed())
+		})
+	})
+
+	When("client doesn't send the complete query", func() {
+		It("should cancel the stream after the read timeout", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			DeferCleanup(cancel)
+
+			tlsConfig := clientTLSConfig()
+			tlsConfig.NextProtos = []string{doqALPN}
+
+			conn, err := quic.DialAddr(ctx, doqAddress, tlsConfig, nil)
+			Expect(err).Should(Succeed())
+			DeferCleanup(conn.CloseWithError, quic.ApplicationErrorCode(0), "")
+
+			stream, err := conn.OpenStreamSync(ctx)
+			Expect(err).Should(Succeed())
+
+			// only the first byte of the length prefix
+			_, err = stream.Write([]byte{0})
+			Expect(err).Should(Succeed())
+
+			start := time.Now()
+			_, err = stream.Read(make([]byte, 1))
+
+			var streamErr *quic.StreamError
+			Expect(errors.As(err, &streamErr)).Should(BeTrue())
+			Expect(time.Since(start)).Should(BeNumerically("<", doqReadTimeout+time.Second))
+		})
+	})
+})
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"slices"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/mock"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const upstreamTimeout = 2 * time.Second
+
+// forward resolves the question with the upstream resolver, the records of the response are changed by the
+// options of the forwarding. Errors of the upstream result in SERVFAIL.
+func (s *Server) forward(question dns.Question, f *mock.Forward, ctx *requestContext) resolution {
+	upstream := f.Upstream
+	if upstream == "" {
+		upstream = s.cfg.Upstream
+	}
+
+	request := new(dns.Msg)
+	request.SetQuestion(question.Name, question.Qtype)
+	request.Question[0].Qclass = question.Qclass
+	request.SetEdns0(dns.DefaultMsgSize, ctx.dnssecOK)
+
+	client := &dns.Client{Timeout: upstreamTimeout}
+
+	response, _, err := client.Exchange(request, upstream)
+	if err == nil && response.Truncated {
+		client.Net = "tcp"
+		response, _, err = client.Exchange(request, upstream)
+	}
+
+	if err != nil {
+		log.Warnf("can't forward '%s' to '%s': %v", question.Name, upstream, err)
+
+		return resolution{rCode: dns.RcodeServerFailure}
+	}
+
+	return resolution{
+		rCode:     response.Rcode,
+		answers:   rewriteRecords(response.Answer, f),
+		ns:        rewriteRecords(response.Ns, f),
+		extra:     rewriteRecords(response.Extra, f),
+		forwarded: true,
+	}
+}
+
+// rewriteRecords removes the filtered records and the OPT record and replaces the TTL of the other records
+func rewriteRecords(records []dns.RR, f *mock.Forward) []dns.RR {
+	var result []dns.RR
+
+	for _, rr := range records {
+		h := rr.Header()
+
+		if h.Rrtype == dns.TypeOPT || slices.Contains(f.Filter, h.Rrtype) {
+			continue
+		}
+
+		if f.TTL != nil {
+			h.Ttl = *f.TTL
+		}
+
+		result = append(result, rr)
+	}
+
+	return result
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarding", func() {
+	const (
+		upstreamAddress = "127.0.0.1:55354"
+		proxyAddress    = "127.0.0.1:55560"
+		// nothing listens on this port
+		downAddress = "127.0.0.1:55399"
+	)
+
+	// upstream answers all questions with fixed records, names below "missing.example." don't exist
+	upstream := func(rw dns.ResponseWriter, request *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(request)
+
+		q := request.Question[0]
+
+		switch {
+		case dns.IsSubDomain("missing.example.", q.Name):
+			response.Rcode = dns.RcodeNameError
+		case q.Qtype == dns.TypeA:
+			rr, _ := dns.NewRR(q.Name + " 3600 IN A 192.0.2.100")
+			response.Answer = append(response.Answer, rr)
+		case q.Qtype == dns.TypeAAAA:
+			rr, _ := dns.NewRR(q.Name + " 3600 IN AAAA 2001:db8::100")
+			response.Answer = append(response.Answer, rr)
+		}
+
+		_ = rw.WriteMsg(response)
+	}
+
+	query := func(name string, qType uint16) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qType)
+
+		var resp *dns.Msg
+
+		Eventually(func() error {
+			var err error
+			resp, _, err = (&dns.Client{Timeout: 3 * time.Second}).Exchange(msg, proxyAddress)
+
+			return err
+		}, "5s").Should(Succeed())
+
+		return resp
+	}
+
+	BeforeEach(func() {
+		pc, err := net.ListenPacket("udp", upstreamAddress)
+		Expect(err).Should(Succeed())
+
+		upstreamServer := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(upstream)}
+
+		go func() { _ = upstreamServer.ActivateAndServe() }()
+		DeferCleanup(upstreamServer.Shutdown)
+
+		os.Clearenv()
+		os.Setenv("MOKKA_LISTENERS", "udp://"+proxyAddress)
+		os.Setenv("MOKKA_UPSTREAM", upstreamAddress)
+		os.Setenv("MOKKA_RULE_1", `A override.example [match=exact]/NOERROR("A 10.0.0.1 60")`)
+		os.Setenv("MOKKA_RULE_2", `* rewrite.example [match=exact]/FORWARD("ttl=30", "filter=AAAA")`)
+		os.Setenv("MOKKA_RULE_3", `* down.example [match=exact]/FORWARD("`+downAddress+`")`)
+		DeferCleanup(os.Clearenv)
+
+		cfg, err := config.ReadConfig()
+		Expect(err).Should(Succeed())
+
+		srv, err := NewServer(cfg)
+		Expect(err).Should(Succeed())
+
+		srv.Start()
+		DeferCleanup(srv.Stop)
+	})
+
+	It("should answer overridden names with the rule", func() {
+		resp := query("override.example.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).Should(BeDNSRecord("override.example.", dns.TypeA, 60, "10.0.0.1"))
+	})
+
+	It("should forward other names to the upstream", func() {
+		resp := query("www.example.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Authoritative).Should(BeFalse())
+		Expect(resp.Answer).Should(BeDNSRecord("www.example.", dns.TypeA, 3600, "192.0.2.100"))
+
+		resp = query("www.missing.example.", dns.TypeA)
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+	})
+
+	It("should rewrite the TTL and filter the records of the upstream", func() {
+		resp := query("rewrite.example.", dns.TypeA)
+		Expect(resp.Answer).Should(BeDNSRecord("rewrite.example.", dns.TypeA, 30, "192.0.2.100"))
+
+		resp = query("rewrite.example.", dns.TypeAAAA)
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).Should(BeEmpty())
+	})
+
+	It("should answer with SERVFAIL if the upstream is not reachable", func() {
+		resp := query("down.example.", dns.TypeA)
+		Expect(resp.Rcode).Should(Equal(dns.RcodeServerFailure))
+	})
+})
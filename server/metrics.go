@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsPath      = "/metrics"
+	metricsNamespace = "mokka"
+	// the duration buckets range from 100µs to 26s
+	minDurationBucket    = 0.0001
+	durationBucketFactor = 4
+	durationBuckets      = 10
+)
+
+// metrics contains the Prometheus metrics of the handled requests, each server has its own registry
+type metrics struct {
+	registry *prometheus.Registry
+	queries  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "queries_total",
+			Help:      "Number of handled requests by query type, transport, response code and matched rule",
+		}, []string{"qtype", "transport", "rcode", "rule"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_duration_seconds",
+			Help:      "Duration of the request handling including delays of the rules",
+			Buckets:   prometheus.ExponentialBuckets(minDurationBucket, durationBucketFactor, durationBuckets),
+		}, []string{"transport"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "queries_in_flight",
+			Help:      "Number of requests which are currently handled",
+		}, []string{"transport"}),
+	}
+
+	m.registry.MustRegister(
+		m.queries,
+		m.duration,
+		m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// begin counts the request as in flight
+func (m *metrics) begin(ctx *requestContext) {
+	m.inFlight.WithLabelValues(ctx.transport).Inc()
+}
+
+// end records the handled request, the labels are empty if the request has no question, no rule matched
+// or no response was written
+func (m *metrics) end(request *dns.Msg, ctx *requestContext, duration time.Duration) {
+	m.inFlight.WithLabelValues(ctx.transport).Dec()
+	m.duration.WithLabelValues(ctx.transport).Observe(duration.Seconds())
+
+	var qType, rCode, rule string
+
+	if len(request.Question) > 0 {
+		qType = dns.Type(request.Question[0].Qtype).String()
+	}
+
+	if ctx.response != nil {
+		rCode = dns.RcodeToString[ctx.response.Rcode]
+	}
+
+	if ctx.rule != nil {
+		rule = ctx.rule.Name
+	}
+
+	m.queries.WithLabelValues(qType, ctx.transport, rCode, rule).Inc()
+}
+
+// handler serves the metrics in the Prometheus text format
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
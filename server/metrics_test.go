@@ -0,0 +1,40 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	scrape := func() string {
+		resp, err := http.Get(adminURL + metricsPath)
+		Expect(err).Should(Succeed())
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).Should(Succeed())
+
+		return string(body)
+	}
+
+	It("should count the queries and expose them via admin API", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("exact.test.", dns.TypeA)
+
+		_, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		// the metrics are recorded after the response was written
+		Eventually(scrape).Should(And(
+			MatchRegexp(`mokka_queries_total\{qtype="A",rcode="NOERROR",rule="9",transport="udp"\} \d+`),
+			ContainSubstring(`mokka_query_duration_seconds_bucket{transport="udp"`),
+			ContainSubstring(`mokka_queries_in_flight{transport="udp"}`),
+			ContainSubstring("go_goroutines"),
+		))
+	})
+})
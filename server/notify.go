@@ -0,0 +1,216 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	notifyPath        = "/notify"
+	notifySendPath    = "/notify/send"
+	zoneParam         = "zone"
+	serialParam       = "serial"
+	notifyJournalSize = 1000
+	notifyTimeout     = 2 * time.Second
+)
+
+// notifyEntry is a received NOTIFY message
+type notifyEntry struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+	Zone   string    `json:"zone"`
+	Serial *uint32   `json:"serial,omitempty"`
+	RCode  string    `json:"rcode"`
+}
+
+// notifyJournal keeps the last received NOTIFY messages
+type notifyJournal struct {
+	mu      sync.Mutex
+	entries []notifyEntry
+}
+
+func (j *notifyJournal) add(e notifyEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) >= notifyJournalSize {
+		j.entries = j.entries[1:]
+	}
+
+	j.entries = append(j.entries, e)
+}
+
+func (j *notifyJournal) list() []notifyEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]notifyEntry{}, j.entries...)
+}
+
+func (j *notifyJournal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = nil
+}
+
+// handleNotify acknowledges NOTIFY messages (RFC 1996) with the response code of the matching rule
+// with the "notify" opcode and records them in the journal. Dropped messages are not recorded.
+func (s *Server) handleNotify(rw dns.ResponseWriter, request *dns.Msg, ctx *requestContext) {
+	rCode, drop := s.notify(request, ctx)
+	if drop {
+		return
+	}
+
+	entry := notifyEntry{
+		Time:  time.Now(),
+		RCode: dns.RcodeToString[rCode],
+	}
+
+	if ctx.clientIP != nil {
+		entry.Client = ctx.clientIP.String()
+	}
+
+	if len(request.Question) > 0 {
+		entry.Zone = request.Question[0].Name
+	}
+
+	for _, rr := range request.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			serial := soa.Serial
+			entry.Serial = &serial
+		}
+	}
+
+	log.Infof("received NOTIFY for zone '%s' from '%s'", entry.Zone, entry.Client)
+
+	s.notifications.add(entry)
+
+	response := new(dns.Msg)
+	response.SetRcode(request, rCode)
+	response.Authoritative = true
+
+	s.writeResponse(rw, request, response, ctx)
+}
+
+// notify returns the response code of the matching rule or NOTAUTH if no rule matches,
+// drop is set if the rule doesn't answer (DROP())
+func (s *Server) notify(request *dns.Msg, ctx *requestContext) (rCode int, drop bool) {
+	if len(request.Question) != 1 {
+		return dns.RcodeFormatError, false
+	}
+
+	rule := s.matchRule(request.Question[0], ctx)
+	if rule == nil {
+		return dns.RcodeNotAuth, false
+	}
+
+	result := s.executeRule(rule)
+
+	return result.RCode, result.Drop
+}
+
+// notifyResult is the response of a NOTIFY target
+type notifyResult struct {
+	Target string `json:"target"`
+	RCode  string `json:"rcode,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// sendNotify sends a NOTIFY message for the zone to all targets, the SOA record of the zone
+// is added to the answer section if the zone is loaded or the serial is defined
+func (s *Server) sendNotify(zoneName string, serial *uint32) []notifyResult {
+	msg := new(dns.Msg)
+	msg.SetNotify(dns.Fqdn(zoneName))
+
+	var soa *dns.SOA
+
+	if z, ok := s.zones.find(zoneName); ok {
+		soa = dns.Copy(z.SOA).(*dns.SOA)
+	} else if serial != nil {
+		soa = &dns.SOA{
+			Hdr:  dns.RR_Header{Name: dns.Fqdn(zoneName), Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+			Ns:   ".",
+			Mbox: ".",
+		}
+	}
+
+	if soa != nil {
+		if serial != nil {
+			soa.Serial = *serial
+		}
+
+		msg.Answer = []dns.RR{soa}
+	}
+
+	c := dns.Client{Timeout: notifyTimeout}
+
+	results := make([]notifyResult, 0, len(s.cfg.NotifyTargets))
+
+	for _, target := range s.cfg.NotifyTargets {
+		result := notifyResult{Target: target}
+
+		resp, _, err := c.Exchange(msg, target)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.RCode = dns.RcodeToString[resp.Rcode]
+		}
+
+		log.Infof("sent NOTIFY for zone '%s' to '%s': %s%s", zoneName, target, result.RCode, result.Error)
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// onNotifyJournal returns the received NOTIFY messages
+func (s *Server) onNotifyJournal(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.notifications.list())
+}
+
+// onNotifyJournalClear removes all received NOTIFY messages
+func (s *Server) onNotifyJournalClear(w http.ResponseWriter, _ *http.Request) {
+	s.notifications.clear()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// onNotifySend sends NOTIFY messages for the zone in the "zone" parameter, the optional
+// "serial" parameter overrides the serial of the zone
+func (s *Server) onNotifySend(w http.ResponseWriter, r *http.Request) {
+	zoneName := r.URL.Query().Get(zoneParam)
+	if zoneName == "" {
+		http.Error(w, "parameter 'zone' is missing", http.StatusBadRequest)
+
+		return
+	}
+
+	var serial *uint32
+
+	if v := r.URL.Query().Get(serialParam); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			http.Error(w, "parameter 'serial' is invalid", http.StatusBadRequest)
+
+			return
+		}
+
+		s32 := uint32(parsed)
+		serial = &s32
+	}
+
+	if len(s.cfg.NotifyTargets) == 0 {
+		http.Error(w, "no NOTIFY targets configured", http.StatusBadRequest)
+
+		return
+	}
+
+	writeJSON(w, s.sendNotify(zoneName, serial))
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func notifyJournalEntries() []notifyEntry {
+	resp, err := http.Get(adminURL + notifyPath)
+	Expect(err).Should(Succeed())
+
+	defer resp.Body.Close()
+
+	Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+
+	var entries []notifyEntry
+	Expect(json.NewDecoder(resp.Body).Decode(&entries)).Should(Succeed())
+
+	return entries
+}
+
+func sendNotifyRequest(query string) *http.Response {
+	resp, err := http.Post(adminURL+notifySendPath+"?"+query, "", nil)
+	Expect(err).Should(Succeed())
+	DeferCleanup(resp.Body.Close)
+
+	return resp
+}
+
+var _ = Describe("NOTIFY", func() {
+	BeforeEach(func() {
+		req, err := http.NewRequest(http.MethodDelete, adminURL+notifyPath, nil)
+		Expect(err).Should(Succeed())
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).Should(Succeed())
+		resp.Body.Close()
+		Expect(resp.StatusCode).Should(Equal(http.StatusNoContent))
+	})
+
+	When("NOTIFY is received", func() {
+		notify := func(zoneName string) *dns.Msg {
+			msg := new(dns.Msg)
+			msg.SetNotify(zoneName)
+			msg.Answer = []dns.RR{newRR(zoneName + " 0 IN SOA ns1. hostmaster. 5 3600 600 86400 60")}
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Opcode).Should(Equal(dns.OpcodeNotify))
+
+			return resp
+		}
+
+		It("should acknowledge it with the response code of the rule and record it", func() {
+			resp := notify("notify.test.")
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Authoritative).Should(BeTrue())
+
+			entries := notifyJournalEntries()
+			Expect(entries).Should(HaveLen(1))
+			Expect(entries[0].Zone).Should(Equal("notify.test."))
+			Expect(entries[0].Client).Should(Equal("127.0.0.1"))
+			Expect(*entries[0].Serial).Should(BeNumerically("==", 5))
+			Expect(entries[0].RCode).Should(Equal("NOERROR"))
+		})
+
+		It("should return the configured response code", func() {
+			Expect(notify("refused.test.").Rcode).Should(Equal(dns.RcodeRefused))
+			Expect(notifyJournalEntries()[0].RCode).Should(Equal("REFUSED"))
+		})
+
+		It("should return NOTAUTH if no rule matches", func() {
+			Expect(notify("other.test.").Rcode).Should(Equal(dns.RcodeNotAuth))
+		})
+	})
+
+	When("NOTIFY is triggered", func() {
+		It("should send it to the targets", func() {
+			resp := sendNotifyRequest("zone=notify.test&serial=7")
+			Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+
+			var results []notifyResult
+			Expect(json.NewDecoder(resp.Body).Decode(&results)).Should(Succeed())
+			Expect(results).Should(Equal([]notifyResult{{Target: "127.0.0.1" + address, RCode: "NOERROR"}}))
+
+			entries := notifyJournalEntries()
+			Expect(entries).Should(HaveLen(1))
+			Expect(*entries[0].Serial).Should(BeNumerically("==", 7))
+		})
+
+		It("should use the serial of a loaded zone", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion("www.mokka.test.", dns.TypeA)
+			_, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+
+			resp := sendNotifyRequest("zone=mokka.test")
+			Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+
+			entries := notifyJournalEntries()
+			Expect(entries).Should(HaveLen(1))
+			Expect(entries[0].Zone).Should(Equal("mokka.test."))
+			Expect(*entries[0].Serial).Should(BeNumerically("==", 1))
+		})
+
+		It("should fail without zone", func() {
+			Expect(sendNotifyRequest("serial=1").StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should fail with invalid serial", func() {
+			Expect(sendNotifyRequest("zone=notify.test&serial=abc").StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const tcpLengthPrefix = 2
+
+// writePcap writes the query and the response (if one was written) of the request to the capture file.
+// Encrypted transports are written in plain text: DoT and DoH as DNS over TCP, DoQ as DNS over UDP.
+func (s *Server) writePcap(request *dns.Msg, ctx *requestContext) {
+	client, listener, ok := pcapAddresses(ctx.remoteAddr, ctx.localAddr)
+	if !ok {
+		return
+	}
+
+	if err := s.writePcapMessage(ctx.start, client, listener, request, ctx.transport); err != nil {
+		log.Errorf("can't write query to pcap file: %v", err)
+
+		return
+	}
+
+	if ctx.response == nil {
+		return
+	}
+
+	if err := s.writePcapMessage(time.Now(), listener, client, ctx.response, ctx.transport); err != nil {
+		log.Errorf("can't write response to pcap file: %v", err)
+	}
+}
+
+func (s *Server) writePcapMessage(t time.Time, src, dst netip.AddrPort, msg *dns.Msg, transport string) error {
+	wire, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	switch transport {
+	case config.ProtocolTCP, config.ProtocolDoT, config.ProtocolDoH:
+		// for example a zone transfer which is written as one response
+		if len(wire) > dns.MaxMsgSize {
+			return fmt.Errorf("message of %d bytes doesn't fit into the length prefix", len(wire))
+		}
+
+		segment := make([]byte, tcpLengthPrefix, tcpLengthPrefix+len(wire))
+		binary.BigEndian.PutUint16(segment, uint16(len(wire)))
+
+		return s.pcap.WriteTCP(t, src, dst, append(segment, wire...))
+	}
+
+	return s.pcap.WriteUDP(t, src, dst, wire)
+}
+
+// pcapAddresses returns the addresses of the client and the listener with the same IP version. A listener which is
+// bound to all interfaces of the other IP version gets the unspecified address of the client's IP version.
+func pcapAddresses(remote, local net.Addr) (client, listener netip.AddrPort, ok bool) {
+	client, listener = addrPort(remote), addrPort(local)
+	if !client.IsValid() {
+		return client, listener, false
+	}
+
+	if !listener.Addr().IsValid() || listener.Addr().Is4() != client.Addr().Is4() {
+		unspecified := netip.IPv6Unspecified()
+		if client.Addr().Is4() {
+			unspecified = netip.IPv4Unspecified()
+		}
+
+		listener = netip.AddrPortFrom(unspecified, listener.Port())
+	}
+
+	return client, listener, true
+}
+
+// addrPort converts the network address, IPv4-mapped IPv6 addresses are converted to IPv4
+func addrPort(addr net.Addr) netip.AddrPort {
+	if addr == nil {
+		return netip.AddrPort{}
+	}
+
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.AddrPort{}
+	}
+
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/binary"
+	"os"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("pcap", func() {
+	type packet struct {
+		protocol byte
+		srcPort  uint16
+		dstPort  uint16
+		msg      *dns.Msg
+	}
+
+	// captured returns the IPv4 packets of the capture file with the message ID
+	captured := func(id uint16) []packet {
+		const (
+			fileHeaderLength   = 24
+			recordHeaderLength = 16
+			ipv4HeaderLength   = 20
+			udpHeaderLength    = 8
+		)
+
+		data, err := os.ReadFile(pcapFile)
+		Expect(err).Should(Succeed())
+
+		data = data[fileHeaderLength:]
+
+		var result []packet
+
+		for len(data) > 0 {
+			length := binary.LittleEndian.Uint32(data[8:])
+			ip := data[recordHeaderLength : recordHeaderLength+length]
+			data = data[recordHeaderLength+length:]
+
+			if ip[0] != 0x45 {
+				continue
+			}
+
+			p := packet{
+				protocol: ip[9],
+				srcPort:  binary.BigEndian.Uint16(ip[ipv4HeaderLength:]),
+				dstPort:  binary.BigEndian.Uint16(ip[ipv4HeaderLength+2:]),
+				msg:      new(dns.Msg),
+			}
+
+			payload := ip[ipv4HeaderLength+udpHeaderLength:]
+			if p.protocol == 6 {
+				// TCP header length and the length prefix of the message
+				payload = ip[ipv4HeaderLength+int(ip[ipv4HeaderLength+12]>>4)*4+2:]
+			}
+
+			Expect(p.msg.Unpack(payload)).Should(Succeed())
+
+			if p.msg.Id == id {
+				result = append(result, p)
+			}
+		}
+
+		return result
+	}
+
+	It("should capture the query and the response with synthesized headers", func() {
+		for _, network := range []string{"udp", "tcp"} {
+			msg := new(dns.Msg)
+			msg.SetQuestion("exact.test.", dns.TypeA)
+
+			_, err := requestServer(msg, network)
+			Expect(err).Should(Succeed())
+
+			protocol := map[string]byte{"udp": 17, "tcp": 6}[network]
+
+			Eventually(func() []packet { return captured(msg.Id) }).Should(HaveLen(2))
+
+			packets := captured(msg.Id)
+			query, response := packets[0], packets[1]
+
+			Expect(query.protocol).Should(Equal(protocol))
+			Expect(query.dstPort).Should(BeEquivalentTo(55555))
+			Expect(query.msg.Response).Should(BeFalse())
+
+			Expect(response.protocol).Should(Equal(protocol))
+			Expect(response.srcPort).Should(BeEquivalentTo(55555))
+			Expect(response.dstPort).Should(Equal(query.srcPort))
+			Expect(response.msg.Answer).Should(HaveLen(1))
+		}
+	})
+})
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROXY protocol, see https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+const (
+	proxyV1Prefix       = "PROXY "
+	proxyV1MaxLength    = 107
+	proxyV2HeaderLength = 16
+	proxyV2Version      = 0x2
+	proxyV2CmdLocal     = 0x0
+	proxyV2CmdProxy     = 0x1
+	proxyV2FamilyInet   = 0x1
+	proxyV2FamilyInet6  = 0x2
+	proxyV2IPv4Length   = 12
+	proxyV2IPv6Length   = 36
+	proxyHeaderTimeout  = 5 * time.Second
+	proxyV2Signature    = "\r\n\r\n\x00\r\nQUIT\n"
+)
+
+// proxyListener expects a PROXY protocol header (v1 or v2) at the beginning of each connection
+type proxyListener struct {
+	net.Listener
+}
+
+func newProxyListener(l net.Listener) net.Listener {
+	return &proxyListener{Listener: l}
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyConn{Conn: conn}, nil
+}
+
+// proxyConn reads the header lazily on the first Read or RemoteAddr call, so Accept doesn't block
+type proxyConn struct {
+	net.Conn
+
+	once       sync.Once
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReader(c.Conn)
+
+		if err := c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout)); err != nil {
+			c.err = err
+
+			return
+		}
+
+		addr, err := readProxyHeader(c.reader)
+		if err != nil {
+			c.err = fmt.Errorf("can't read PROXY protocol header: %w", err)
+
+			return
+		}
+
+		if addr.IP != nil {
+			c.remoteAddr = &addr
+		}
+
+		c.err = c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header or the address of the proxy if not present
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader returns the source address, the IP is nil if the header doesn't contain an address (UNKNOWN or LOCAL)
+func readProxyHeader(r *bufio.Reader) (net.TCPAddr, error) {
+	prefix, err := r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return net.TCPAddr{}, err
+	}
+
+	if string(prefix) == proxyV1Prefix {
+		return readProxyV1Header(r)
+	}
+
+	return readProxyV2Header(r)
+}
+
+// readProxyV1Header parses the human-readable format, for example "PROXY TCP4 192.0.2.1 192.0.2.2 56324 53\r\n"
+func readProxyV1Header(r *bufio.Reader) (net.TCPAddr, error) {
+	var line []byte
+
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return net.TCPAddr{}, errors.New("v1 header is too long")
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return net.TCPAddr{}, err
+		}
+
+		line = append(line, b)
+	}
+
+	const (
+		fieldCount     = 6
+		fieldProtocol  = 1
+		fieldSourceIP  = 2
+		fieldSrcPort   = 4
+		unknownAddress = "UNKNOWN"
+	)
+
+	fields := strings.Fields(string(line))
+	if len(fields) > fieldProtocol && fields[fieldProtocol] == unknownAddress {
+		return net.TCPAddr{}, nil
+	}
+
+	if len(fields) != fieldCount || (fields[fieldProtocol] != "TCP4" && fields[fieldProtocol] != "TCP6") {
+		return net.TCPAddr{}, fmt.Errorf("invalid v1 header '%s'", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[fieldSourceIP])
+	if ip == nil {
+		return net.TCPAddr{}, fmt.Errorf("invalid source address '%s'", fields[fieldSourceIP])
+	}
+
+	port, err := strconv.ParseUint(fields[fieldSrcPort], 10, 16)
+	if err != nil {
+		return net.TCPAddr{}, fmt.Errorf("invalid source port '%s'", fields[fieldSrcPort])
+	}
+
+	return net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2Header parses the binary format, TLVs are ignored
+func readProxyV2Header(r *bufio.Reader) (net.TCPAddr, error) {
+	header := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return net.TCPAddr{}, err
+	}
+
+	if string(header[:len(proxyV2Signature)]) != proxyV2Signature {
+		return net.TCPAddr{}, errors.New("header is missing")
+	}
+
+	versionCommand, family := header[12], header[13]
+	if versionCommand>>4 != proxyV2Version {
+		return net.TCPAddr{}, fmt.Errorf("unsupported version %d", versionCommand>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return net.TCPAddr{}, err
+	}
+
+	switch versionCommand & 0xF {
+	case proxyV2CmdLocal:
+		return net.TCPAddr{}, nil
+	case proxyV2CmdProxy:
+	default:
+		return net.TCPAddr{}, fmt.Errorf("unsupported command %d", versionCommand&0xF)
+	}
+
+	switch {
+	case family>>4 == proxyV2FamilyInet && len(payload) >= proxyV2IPv4Length:
+		return net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case family>>4 == proxyV2FamilyInet6 && len(payload) >= proxyV2IPv6Length:
+		return net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+
+	// unspecified or unix socket addresses
+	return net.TCPAddr{}, nil
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func proxyV2Header(command byte, family byte, addresses []byte) string {
+	header := []byte(proxyV2Signature)
+	header = append(header, proxyV2Version<<4|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+
+	return string(append(header, addresses...))
+}
+
+var _ = Describe("PROXY protocol", func() {
+	DescribeTable("parsing the header",
+		func(header, expectedIP string, expectedPort int) {
+			r := bufio.NewReader(strings.NewReader(header + "payload"))
+
+			addr, err := readProxyHeader(r)
+			Expect(err).Should(Succeed())
+
+			if expectedIP == "" {
+				Expect(addr.IP).Should(BeNil())
+			} else {
+				Expect(addr.IP.String()).Should(Equal(expectedIP))
+				Expect(addr.Port).Should(Equal(expectedPort))
+			}
+
+			rest, err := r.ReadString(0)
+			Expect(rest).Should(Equal("payload"))
+			Expect(err).Should(HaveOccurred())
+		},
+		Entry("v1 TCP4", "PROXY TCP4 192.0.2.1 192.0.2.2 56324 53\r\n", "192.0.2.1", 56324),
+		Entry("v1 TCP6", "PROXY TCP6 2001:db8::1 2001:db8::2 4242 53\r\n", "2001:db8::1", 4242),
+		Entry("v1 UNKNOWN", "PROXY UNKNOWN\r\n", "", 0),
+		Entry("v2 IPv4", proxyV2Header(proxyV2CmdProxy, 0x11,
+			[]byte{192, 0, 2, 1, 192, 0, 2, 2, 0x12, 0x34, 0, 53}), "192.0.2.1", 0x1234),
+		Entry("v2 IPv6 with TLV", proxyV2Header(proxyV2CmdProxy, 0x21,
+			append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...),
+				0x10, 0x00, 0, 53, 0x04, 0x00, 0x01, 0xFF)), "2001:db8::1", 0x1000),
+		Entry("v2 LOCAL", proxyV2Header(proxyV2CmdLocal, 0x00, nil), "", 0),
+	)
+
+	DescribeTable("invalid header",
+		func(header, expectedError string) {
+			_, err := readProxyHeader(bufio.NewReader(strings.NewReader(header)))
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring(expectedError))
+		},
+		Entry("missing header", "\x00\x1c\x00\x01\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "header is missing"),
+		Entry("invalid v1 protocol", "PROXY UDP4 192.0.2.1 192.0.2.2 56324 53\r\n", "invalid v1 header"),
+		Entry("invalid v1 address", "PROXY TCP4 192.0.2.x 192.0.2.2 56324 53\r\n", "invalid source address"),
+		Entry("too long v1 header", "PROXY "+strings.Repeat("A", 200), "v1 header is too long"),
+		Entry("v2 unsupported command", proxyV2Header(0x5, 0x11, nil), "unsupported command 5"),
+	)
+
+	When("TCP listener has PROXY protocol enabled", func() {
+		const proxyAddress = "127.0.0.1:55559"
+
+		BeforeEach(func() {
+			os.Clearenv()
+			os.Setenv("MOKKA_LISTENERS", "tcp://"+proxyAddress+"?proxy=true")
+			os.Setenv("MOKKA_RULE_1", `A proxy.test [client=203.0.113.0/24]/NOERROR("A 1.1.1.1 1")`)
+			os.Setenv("MOKKA_RULE_2", `A proxy.test/NOERROR("A 2.2.2.2 1")`)
+			DeferCleanup(os.Clearenv)
+
+			cfg, err := config.ReadConfig()
+			Expect(err).Should(Succeed())
+			Expect(cfg.Listeners[0].ProxyProtocol).Should(BeTrue())
+
+			srv, err := NewServer(cfg)
+			Expect(err).Should(Succeed())
+
+			srv.Start()
+			DeferCleanup(srv.Stop)
+		})
+
+		exchange := func(header string) (*dns.Msg, error) {
+			var conn net.Conn
+
+			Eventually(func() (err error) {
+				conn, err = net.Dial("tcp", proxyAddress)
+
+				return err
+			}, "5s").Should(Succeed())
+			defer conn.Close()
+
+			if _, err := conn.Write([]byte(header)); err != nil {
+				return nil, err
+			}
+
+			msg := new(dns.Msg)
+			msg.SetQuestion("proxy.test.", dns.TypeA)
+
+			dnsConn := &dns.Conn{Conn: conn}
+			if err := dnsConn.WriteMsg(msg); err != nil {
+				return nil, err
+			}
+
+			return dnsConn.ReadMsg()
+		}
+
+		It("should use the client address from the header", func() {
+			resp, err := exchange("PROXY TCP4 203.0.113.7 127.0.0.1 12345 53\r\n")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("proxy.test.", dns.TypeA, 1, "1.1.1.1"))
+		})
+
+		It("should use the proxy address for LOCAL command", func() {
+			resp, err := exchange(proxyV2Header(proxyV2CmdLocal, 0x00, nil))
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("proxy.test.", dns.TypeA, 1, "2.2.2.2"))
+		})
+
+		It("should close the connection without header", func() {
+			_, err := exchange("")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
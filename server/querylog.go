@@ -0,0 +1,42 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/querylog"
+	"github.com/miekg/dns"
+)
+
+// newQuery creates the query log entry, the question fields are empty if the request has no question
+func newQuery(request *dns.Msg, ctx *requestContext, duration time.Duration) querylog.Query {
+	q := querylog.Query{
+		Time:       ctx.start,
+		Transport:  ctx.transport,
+		DurationMS: float64(duration) / float64(time.Millisecond),
+	}
+
+	if ctx.clientIP != nil {
+		q.Client = ctx.clientIP.String()
+	}
+
+	if len(request.Question) > 0 {
+		q.QName = request.Question[0].Name
+		q.QType = dns.Type(request.Question[0].Qtype).String()
+	}
+
+	if ctx.rule != nil {
+		q.Rule = ctx.rule.Name
+	}
+
+	if ctx.response != nil {
+		q.RCode = dns.RcodeToString[ctx.response.Rcode]
+
+		for _, rr := range ctx.response.Answer {
+			// the fields of the presentation format are separated by tabs
+			q.Answers = append(q.Answers, strings.Join(strings.Fields(rr.String()), " "))
+		}
+	}
+
+	return q
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/0xERR0R/dns-mokka/querylog"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query log", func() {
+	// loggedQueries returns the queries of the log file with the name
+	loggedQueries := func(name string) []querylog.Query {
+		f, err := os.Open(queryLogFile)
+		Expect(err).Should(Succeed())
+
+		defer f.Close()
+
+		var result []querylog.Query
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var q querylog.Query
+			Expect(json.Unmarshal(scanner.Bytes(), &q)).Should(Succeed())
+
+			if q.QName == name {
+				result = append(result, q)
+			}
+		}
+
+		return result
+	}
+
+	It("should log the query with the matched rule and the answers", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("dest.test.", dns.TypeA)
+
+		_, err := requestServer(msg, "tcp")
+		Expect(err).Should(Succeed())
+
+		Eventually(func() []querylog.Query { return loggedQueries("dest.test.") }).Should(ContainElement(And(
+			HaveField("Client", "127.0.0.1"),
+			HaveField("Transport", "tcp"),
+			HaveField("QType", "A"),
+			HaveField("Rule", "B3"),
+			HaveField("RCode", "NOERROR"),
+			HaveField("Answers", Equal([]string{"dest.test. 1 IN A 1.2.3.10"})),
+		)))
+	})
+
+	It("should log unmatched queries without rule", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("none.invalid.", dns.TypeTXT)
+
+		_, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		Eventually(func() []querylog.Query { return loggedQueries("none.invalid.") }).Should(ContainElement(And(
+			HaveField("Rule", ""),
+			HaveField("RCode", "NXDOMAIN"),
+			HaveField("Answers", BeEmpty()),
+		)))
+	})
+	It("should log the name of the default response for unmatched queries", func() {
+		msg := new(dns.Msg)
+		msg.SetQuestion("none.invalid.", dns.TypeSRV)
+
+		_, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		Eventually(func() []querylog.Query { return loggedQueries("none.invalid.") }).Should(ContainElement(And(
+			HaveField("QType", "SRV"),
+			HaveField("Rule", "default_SRV"),
+			HaveField("RCode", "REFUSED"),
+		)))
+	})
+})
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/0xERR0R/dns-mokka/dnssec"
 	"github.com/0xERR0R/dns-mokka/mock"
+	"github.com/0xERR0R/dns-mokka/pcap"
+	"github.com/0xERR0R/dns-mokka/querylog"
+	"github.com/0xERR0R/dns-mokka/zone"
 	"github.com/mattn/anko/env"
 	"github.com/mattn/anko/vm"
 	"github.com/miekg/dns"
@@ -13,17 +20,29 @@ import (
 )
 
 type Server struct {
-	dnsServers []*dns.Server
+	transports []transport
 	cfg        *config.Config
 	env        *env.Env
+	tlsConfig  *tls.Config
+	zones      *zoneStore
+	// notifications contains the received NOTIFY messages
+	notifications *notifyJournal
+	// signer signs the answers of the DNSSEC zones
+	signer *dnssec.Signer
+	// ruleNames contains the names of the rules in the DNSSEC zones, used to prove negative answers
+	ruleNames map[string]dnssec.Names
+	metrics   *metrics
+	// queryLog writes the handled requests, nil if the query log is disabled
+	queryLog *querylog.Logger
+	// dnstap writes the handled requests as dnstap messages, nil if dnstap is disabled
+	dnstap *dnstapWriter
+	// pcap writes the handled requests to a capture file, nil if the capture is disabled
+	pcap *pcap.Writer
+	// coverage counts the rule matches and the questions without matching rule
+	coverage *coverage
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
-	dnsServers := []*dns.Server{
-		createUDPServer(cfg.ListenAddress),
-		createTCPServer(cfg.ListenAddress),
-	}
-
 	env, err := mock.CreateEnv()
 
 	if err != nil {
@@ -31,131 +50,569 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	}
 
 	s := &Server{
-		dnsServers: dnsServers,
-		cfg:        cfg,
-		env:        env,
+		cfg:           cfg,
+		env:           env,
+		zones:         newZoneStore(),
+		notifications: &notifyJournal{},
+		metrics:       newMetrics(),
+		coverage:      newCoverage(cfg.Rules),
+	}
+
+	if s.signer, err = createSigner(cfg.DNSSEC); err != nil {
+		return nil, fmt.Errorf("can't create DNSSEC keys: %w", err)
+	}
+
+	s.ruleNames = s.createRuleNames()
+
+	if cfg.QueryLog.Target != "" {
+		s.queryLog = querylog.New(cfg.QueryLog)
+	}
+
+	if cfg.DNStap.Enabled() {
+		if s.dnstap, err = newDNStapWriter(cfg.DNStap); err != nil {
+			return nil, fmt.Errorf("can't create dnstap output: %w", err)
+		}
+	}
+
+	if cfg.PcapFile != "" {
+		if s.pcap, err = pcap.Create(cfg.PcapFile); err != nil {
+			return nil, fmt.Errorf("can't create pcap file: %w", err)
+		}
+	}
+
+	for _, l := range cfg.Listeners {
+		t, err := s.createTransport(l)
+		if err != nil {
+			return nil, fmt.Errorf("can't create listener '%s': %w", l, err)
+		}
+
+		s.transports = append(s.transports, t)
 	}
 
-	for _, server := range s.dnsServers {
-		handler := server.Handler.(*dns.ServeMux)
-		handler.HandleFunc(".", s.OnRequest)
+	if cfg.AdminAddress != "" {
+		s.transports = append(s.transports, s.createAdminServer(cfg.AdminAddress))
 	}
 
 	return s, nil
 }
 
-func createUDPServer(address string) *dns.Server {
+func (s *Server) createDNSServer(l config.Listener) *dns.Server {
 	const maxUDPSize = 65535
 
-	return &dns.Server{
-		Addr:    address,
-		Net:     "udp",
-		Handler: dns.NewServeMux(),
+	server := &dns.Server{
+		Addr:          l.Address,
+		Net:           l.Network(),
+		Handler:       s.handler(l),
+		MsgAcceptFunc: acceptMsg,
+		TsigSecret:    tsigSecrets(s.cfg.TSIGKeys),
 		NotifyStartedFunc: func() {
-			log.Infof("UDP server is up and running on: '%s'", address)
+			log.Infof("%s server is up and running on: '%s'", strings.ToUpper(l.Protocol), l.Address)
 		},
-		UDPSize: maxUDPSize,
 	}
+
+	if l.Protocol == config.ProtocolUDP {
+		server.UDPSize = maxUDPSize
+	}
+
+	if l.Protocol == config.ProtocolDoT {
+		server.Net += "-tls"
+		server.TLSConfig = s.tlsConfig
+	}
+
+	return server
 }
 
-func createTCPServer(address string) *dns.Server {
-	return &dns.Server{
-		Addr:    address,
-		Net:     "tcp",
-		Handler: dns.NewServeMux(),
-		NotifyStartedFunc: func() {
-			log.Infof("TCP server is up and running on: '%s'", address)
-		},
+// acceptMsg works like dns.DefaultMsgAcceptFunc, but passes queries with zero or multiple questions
+// and dynamic updates to the handler
+func acceptMsg(dh dns.Header) dns.MsgAcceptAction {
+	const (
+		responseBit = 1 << 15
+		opcodeShift = 11
+		opcodeMask  = 0xF
+	)
+
+	isRequest := dh.Bits&responseBit == 0
+	opcode := int(dh.Bits>>opcodeShift) & opcodeMask
+
+	if isRequest && opcode == dns.OpcodeUpdate {
+		// the prerequisite and update sections can contain any number of records
+		return dns.MsgAccept
 	}
+
+	if isRequest && opcode == dns.OpcodeQuery && dh.Qdcount != 1 {
+		// the other sections are validated as usual
+		dh.Qdcount = 1
+	}
+
+	return dns.DefaultMsgAcceptFunc(dh)
 }
 
-// returns EDNS upd size or if not present, 512 for UDP and 64K for TCP
-func getMaxResponseSize(network string, request *dns.Msg) int {
-	edns := request.IsEdns0()
-	if edns != nil && edns.UDPSize() > 0 {
-		return int(edns.UDPSize())
+// returns the network of the transport, DoH and DoQ are stream based like TCP
+func transportNetwork(rw dns.ResponseWriter) string {
+	if _, ok := rw.(*bufferedResponseWriter); ok {
+		return "tcp"
 	}
 
+	return rw.LocalAddr().Network()
+}
+
+// returns 64K for stream transports (TCP, DoT, DoH and DoQ), for UDP the EDNS udp size or if not present 512
+func getMaxResponseSize(network string, request *dns.Msg) int {
 	if network == "tcp" {
 		return dns.MaxMsgSize
 	}
 
+	edns := request.IsEdns0()
+	if edns != nil && edns.UDPSize() > 0 {
+		return int(edns.UDPSize())
+	}
+
 	return dns.MinMsgSize
 }
 
+// requestContext contains the request data which is not part of the DNS message
+type requestContext struct {
+	listener config.Listener
+	clientIP net.IP
+	ecsIP    net.IP
+	opcode   int
+	// tsigKey is the name of the key of a signed and verified request
+	tsigKey string
+	// tsigError is the TSIG error of a signed request which couldn't be verified
+	tsigError uint16
+	// rule is the first matching rule of the request
+	rule *config.Rule
+	// dnssecOK is set if the request has the DO bit, the answers of signed zones are signed
+	dnssecOK bool
+	// transport is the protocol of the listener or the network if the listener is unknown
+	transport string
+	// localAddr and remoteAddr are the addresses of the listener and the client
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	// start is the time the request was received
+	start time.Time
+	// response is the written response or nil if no response was written
+	response *dns.Msg
+}
+
+// OnRequest handles the request with the rules which are not bound to a view
 func (s *Server) OnRequest(rw dns.ResponseWriter, request *dns.Msg) {
-	rulesForType := s.cfg.Rules[dns.Type(request.Question[0].Qtype)]
+	s.handleRequest(rw, request, config.Listener{})
+}
+
+// handler returns the request handler for the listener, the rules are filtered by the view of the listener
+func (s *Server) handler(l config.Listener) dns.Handler {
+	return dns.HandlerFunc(func(rw dns.ResponseWriter, request *dns.Msg) {
+		s.handleRequest(rw, request, l)
+	})
+}
+
+// handleRequest serves the request and records it in the metrics, the query log, dnstap and the capture file
+func (s *Server) handleRequest(rw dns.ResponseWriter, request *dns.Msg, l config.Listener) {
+	ctx := &requestContext{
+		listener:   l,
+		clientIP:   clientIP(rw.RemoteAddr()),
+		ecsIP:      ecsIP(request),
+		opcode:     request.Opcode,
+		dnssecOK:   dnssecOK(request),
+		transport:  l.Protocol,
+		localAddr:  rw.LocalAddr(),
+		remoteAddr: rw.RemoteAddr(),
+		start:      time.Now(),
+	}
+
+	if ctx.transport == "" {
+		ctx.transport = transportNetwork(rw)
+	}
+
+	s.metrics.begin(ctx)
+	defer s.finishRequest(request, ctx)
+
+	s.serve(rw, request, ctx)
+}
+
+// finishRequest records the handled request in the metrics, the query log, dnstap and the capture file
+func (s *Server) finishRequest(request *dns.Msg, ctx *requestContext) {
+	duration := time.Since(ctx.start)
+
+	s.metrics.end(request, ctx, duration)
+
+	if s.queryLog != nil {
+		if err := s.queryLog.Log(newQuery(request, ctx, duration)); err != nil {
+			log.Errorf("can't write query log: %v", err)
+		}
+	}
+
+	if s.dnstap != nil {
+		s.dnstap.write(request, ctx)
+	}
+
+	if s.pcap != nil {
+		s.writePcap(request, ctx)
+	}
+}
+
+// serve answers the request depending on the opcode and the questions
+func (s *Server) serve(rw dns.ResponseWriter, request *dns.Msg, ctx *requestContext) {
+	ctx.tsigKey, ctx.tsigError = s.verifyTSIG(rw, request)
+
+	if ctx.tsigError != dns.RcodeSuccess {
+		response := new(dns.Msg)
+		response.SetRcode(request, dns.RcodeNotAuth)
+		s.writeResponse(rw, request, response, ctx)
+
+		return
+	}
 
-	rCode := dns.RcodeNameError
+	switch request.Opcode {
+	case dns.OpcodeUpdate:
+		s.handleUpdate(rw, request, ctx)
 
-	var answers []dns.RR
+		return
+	case dns.OpcodeNotify:
+		s.handleNotify(rw, request, ctx)
 
-	if rulesForType != nil {
-		answers, rCode = s.processRules(rulesForType, request.Question[0].Name)
+		return
+	}
+
+	if len(request.Question) == 1 && isTransfer(request.Question[0].Qtype) {
+		s.handleTransfer(rw, request, ctx)
+
+		return
 	}
 
 	response := new(dns.Msg)
-	response.SetRcode(request, rCode)
-	response.Answer = answers
+
+	questions := request.Question
+
+	switch {
+	case len(questions) == 0:
+		response.SetRcodeFormatError(request)
+	case len(questions) > 1 && s.cfg.MultiQuestion == config.MultiQuestionFormErr:
+		response.SetRcodeFormatError(request)
+	default:
+		if s.cfg.MultiQuestion != config.MultiQuestionAll {
+			questions = questions[:1]
+		}
+
+		res := s.resolveAll(questions, ctx)
+		if res.drop {
+			log.Debugf("request for '%s' is dropped", questions[0].Name)
+
+			return
+		}
+
+		response.SetRcode(request, res.rCode)
+		response.Question = questions
+		response.Authoritative = res.authoritative
+		response.Answer = res.answers
+		response.Ns = res.ns
+		response.Extra = res.extra
+	}
 
 	response.MsgHdr.RecursionAvailable = request.MsgHdr.RecursionDesired
 
+	if edns := request.IsEdns0(); edns != nil && len(s.cfg.DNSSEC.Zones) > 0 {
+		// validators expect the DO bit in the response
+		response.SetEdns0(edns.UDPSize(), edns.Do())
+	}
+
 	// truncate if necessary
-	response.Truncate(getMaxResponseSize(rw.LocalAddr().Network(), request))
+	response.Truncate(getMaxResponseSize(transportNetwork(rw), request))
 
 	// enable compression
 	response.Compress = true
 
-	if err := rw.WriteMsg(response); err != nil {
-		log.Error("can't write response: ", err)
+	s.writeResponse(rw, request, response, ctx)
+}
+
+// resolution contains the response code and the records of the response sections for questions
+type resolution struct {
+	rCode         int
+	authoritative bool
+	answers       []dns.RR
+	ns            []dns.RR
+	extra         []dns.RR
+	// zone is the zone which answered the question, nil for answers of rules
+	zone *zone.Zone
+	// drop is set if the request must not be answered
+	drop bool
+	// forwarded is set if the upstream resolver answered the question, CNAME chains are already resolved
+	forwarded bool
+}
+
+// resolveAll resolves each question, the response code is the first code which is not NOERROR.
+// The response is authoritative if all answers are authoritative. The request is dropped if one question is dropped.
+func (s *Server) resolveAll(questions []dns.Question, ctx *requestContext) resolution {
+	all := resolution{
+		rCode:         dns.RcodeSuccess,
+		authoritative: true,
+	}
+
+	for _, q := range questions {
+		res := s.resolve(q, ctx)
+
+		all.answers = append(all.answers, res.answers...)
+		all.ns = append(all.ns, res.ns...)
+		all.extra = append(all.extra, res.extra...)
+		all.authoritative = all.authoritative && res.authoritative
+		all.drop = all.drop || res.drop
+
+		if all.rCode == dns.RcodeSuccess {
+			all.rCode = res.rCode
+		}
+	}
+
+	return all
+}
+
+// resolve applies the rules to the question and follows CNAME records in the answer if enabled.
+// The authority and additional sections are taken from the last resolved name of the CNAME chain.
+// A loop and a chain longer than the max depth can't be resolved completely, both result in SERVFAIL
+// with the CNAME records resolved so far (like a recursive resolver giving up).
+func (s *Server) resolve(question dns.Question, ctx *requestContext) resolution {
+	res := s.processRules(question, ctx)
+
+	if !s.cfg.FollowCNAME || res.forwarded || question.Qtype == dns.TypeCNAME || question.Qtype == dns.TypeANY {
+		return res
+	}
+
+	visited := map[string]bool{strings.ToLower(question.Name): true}
+
+	for depth := 1; res.rCode == dns.RcodeSuccess; depth++ {
+		target := cnameTarget(res.answers, question.Name)
+		if target == "" {
+			break
+		}
+
+		if visited[strings.ToLower(target)] {
+			log.Warnf("CNAME loop detected for '%s'", target)
+
+			res.rCode = dns.RcodeServerFailure
+
+			return res
+		}
+
+		if depth > s.cfg.CNAMEMaxDepth {
+			log.Warnf("CNAME chain for '%s' exceeds max depth %d", question.Name, s.cfg.CNAMEMaxDepth)
+
+			res.rCode = dns.RcodeServerFailure
+
+			return res
+		}
+
+		visited[strings.ToLower(target)] = true
+		question.Name = target
+
+		chained := s.processRules(question, ctx)
+
+		res.rCode = chained.rCode
+		res.drop = chained.drop
+		res.answers = append(res.answers, chained.answers...)
+		res.ns = chained.ns
+		res.extra = chained.extra
+
+		if chained.forwarded {
+			break
+		}
+	}
+
+	return res
+}
+
+// returns the target of the CNAME record for the name or empty string if not present
+func cnameTarget(answers []dns.RR, name string) string {
+	for _, a := range answers {
+		if cname, ok := a.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+			return cname.Target
+		}
+	}
+
+	return ""
+}
+
+// processRules applies the first matching rule to the question, the rule answers with its records
+// or with the records of its zone. The keys of signed zones are answered without rules.
+func (s *Server) processRules(question dns.Question, ctx *requestContext) resolution {
+	if res, ok := s.keyRecords(question); ok {
+		return s.sign(res, dnssec.SignatureValid, ctx)
+	}
+
+	res, mode := resolution{rCode: dns.RcodeNameError}, dnssec.SignatureValid
+
+	rule := s.matchRule(question, ctx)
+	if rule == nil {
+		rule = s.defaultRule(question, ctx)
+	}
+
+	if rule != nil {
+		res, mode = s.applyRule(rule, question, ctx), rule.DNSSEC
+	}
+
+	if res.drop {
+		return res
+	}
+
+	return s.sign(s.deny(question, res, ctx), mode, ctx)
+}
+
+// applyRule executes the rule and creates the answers from its records or from the records of its zone
+func (s *Server) applyRule(rule *config.Rule, question dns.Question, ctx *requestContext) resolution {
+	result := s.executeRule(rule)
+
+	if result.Drop {
+		return resolution{drop: true}
+	}
+
+	if result.Forward != nil {
+		return s.forward(question, result.Forward, ctx)
+	}
+
+	if len(result.ZoneFiles) > 0 && result.RCode == dns.RcodeSuccess {
+		return s.lookupZone(rule, result.ZoneFiles, question)
+	}
+
+	answers := createAnswers(question.Name, result.RR)
+
+	if rule.Types == nil {
+		answers = filterAnswers(answers, question.Qtype)
+	}
+
+	return resolution{
+		rCode:   result.RCode,
+		answers: answers,
 	}
 }
 
-func (s *Server) processRules(rulesForType []config.RegexRule, name string) (answers []dns.RR, rCode int) {
-	matched := false
-	for _, rr := range rulesForType {
-		if rr.Regex.MatchString(strings.ToLower(name)) {
-			matched = true
-			res, err := vm.Execute(s.env, nil, rr.Rule)
-			if err != nil {
-				log.Fatalf("can't execute rule '%s': %v", rr.Rule, err)
+// matchRule returns the first rule which matches the question and opcode or nil if no rule matches.
+// The first matching rule of the request is kept in the context, the matches and misses are counted in the coverage.
+func (s *Server) matchRule(question dns.Question, ctx *requestContext) *config.Rule {
+	for i := range s.cfg.Rules {
+		rr := &s.cfg.Rules[i]
+
+		if rr.Opcode == ctx.opcode && rr.MatchesView(ctx.listener.View) && rr.MatchesType(question.Qtype) &&
+			rr.Matcher.Match(question.Name) && rr.MatchesClient(ctx.clientIP, ctx.ecsIP) &&
+			rr.MatchesTSIG(ctx.tsigKey) {
+			if ctx.rule == nil {
+				ctx.rule = rr
 			}
 
-			result := res.(mock.Result)
+			s.coverage.hit(rr)
 
-			rCode = result.RCode
+			return rr
+		}
+	}
+
+	s.coverage.miss(question, ctx.opcode)
 
-			for _, rr := range result.RR {
-				answer, err := dns.NewRR(fmt.Sprintf("%s %d %s %s %s",
-					name, rr.TTL, "IN", rr.RType, rr.Address))
-				if err != nil {
-					log.Fatal("can't create answer", err)
-				}
+	return nil
+}
+
+// defaultRule returns the first default rule which matches the type of the question or nil if the question gets
+// NXDOMAIN. The default rule is kept in the context if no other rule matched.
+func (s *Server) defaultRule(question dns.Question, ctx *requestContext) *config.Rule {
+	for i := range s.cfg.Defaults {
+		d := &s.cfg.Defaults[i]
 
-				answers = append(answers, answer)
+		if d.MatchesType(question.Qtype) {
+			if ctx.rule == nil {
+				ctx.rule = d
 			}
 
-			break
+			return d
 		}
 	}
 
-	if !matched {
-		rCode = dns.RcodeNameError
+	return nil
+}
+
+// executeRule executes the function of the rule, rules with a deliberate TSIG error (except bad MAC)
+// return NOTAUTH without execution
+func (s *Server) executeRule(rule *config.Rule) mock.Result {
+	if ruleTSIGError(rule) != dns.RcodeSuccess {
+		return mock.Result{RCode: dns.RcodeNotAuth}
+	}
+
+	res, err := vm.Execute(s.env, nil, rule.Rule)
+	if err != nil {
+		log.Fatalf("can't execute rule '%s': %v", rule.Rule, err)
+	}
+
+	return res.(mock.Result)
+}
+
+// createAnswers creates the resource records of the rule result with the name as owner
+func createAnswers(name string, records []mock.Record) []dns.RR {
+	answers := make([]dns.RR, 0, len(records))
+
+	for _, rr := range records {
+		answer, err := dns.NewRR(fmt.Sprintf("%s %d %s %s %s",
+			name, rr.TTL, "IN", rr.RType, rr.Address))
+		if err != nil {
+			log.Fatal("can't create answer", err)
+		}
+
+		answers = append(answers, answer)
 	}
 
-	return
+	return answers
+}
+
+// returns only records of the query type (and CNAME records) or all records for ANY queries
+func filterAnswers(answers []dns.RR, qType uint16) []dns.RR {
+	if qType == dns.TypeANY {
+		return answers
+	}
+
+	var filtered []dns.RR
+
+	for _, a := range answers {
+		if a.Header().Rrtype == qType || a.Header().Rrtype == dns.TypeCNAME {
+			filtered = append(filtered, a)
+		}
+	}
+
+	return filtered
+}
+
+// returns the IP address of the client or nil if the address type is unknown
+func clientIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+
+	return nil
+}
+
+// returns the address of the EDNS client subnet option or nil if not present
+func ecsIP(request *dns.Msg) net.IP {
+	edns := request.IsEdns0()
+	if edns == nil {
+		return nil
+	}
+
+	for _, o := range edns.Option {
+		if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return subnet.Address
+		}
+	}
+
+	return nil
 }
 
 // Start starts the server
 func (s *Server) Start() {
 	log.Info("Starting server")
 
-	for _, srv := range s.dnsServers {
-		srv := srv
+	for _, t := range s.transports {
+		t := t
 
 		go func() {
-			if err := srv.ListenAndServe(); err != nil {
-				log.Fatalf("start %s listener failed: %v", srv.Net, err)
+			if err := t.ListenAndServe(); err != nil {
+				log.Fatalf("start %s listener failed: %v", t, err)
 			}
 		}()
 	}
@@ -165,9 +622,27 @@ func (s *Server) Start() {
 func (s *Server) Stop() {
 	log.Info("Stopping server")
 
-	for _, server := range s.dnsServers {
-		if err := server.Shutdown(); err != nil {
-			log.Fatalf("stop %s listener failed: %v", server.Net, err)
+	for _, t := range s.transports {
+		if err := t.Shutdown(); err != nil {
+			log.Fatalf("stop %s listener failed: %v", t, err)
+		}
+	}
+
+	s.coverage.logSummary()
+
+	if s.dnstap != nil {
+		s.dnstap.close()
+	}
+
+	if s.pcap != nil {
+		if err := s.pcap.Close(); err != nil {
+			log.Errorf("can't close pcap file: %v", err)
+		}
+	}
+
+	if s.queryLog != nil {
+		if err := s.queryLog.Close(); err != nil {
+			log.Errorf("can't close query log: %v", err)
 		}
 	}
 }
@@ -1,23 +1,45 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/0xERR0R/dns-mokka/config"
+	dnstap "github.com/dnstap/golang-dnstap"
 	"github.com/miekg/dns"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/quic-go/quic-go"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	sut *Server
+	sut          *Server
+	certFile     string
+	queryLogFile string
+	// dnstapFrames receives the frames of the dnstap socket
+	dnstapFrames chan []byte
+	pcapFile     string
 )
 
-const address = ":55555"
+const (
+	address    = ":55555"
+	dotAddress = ":55853"
+	dohAddress = "localhost:55443"
+	doqAddress = "localhost:55853"
+	adminURL   = "http://127.0.0.1:55080"
+)
 
 var _ = BeforeSuite(func() {
 	os.Setenv("MOKKA_LISTEN_ADDRESS", address)
@@ -25,6 +47,94 @@ var _ = BeforeSuite(func() {
 	os.Setenv("MOKKA_RULE_1", `A google/NOERROR("A 1.2.3.4 123")`)
 	os.Setenv("MOKKA_RULE_3", `A delay.com/delay(NOERROR("A 1.1.1.1 100"), "100ms")`)
 	os.Setenv("MOKKA_RULE_4", `A unknown/NXDOMAIN()`)
+	os.Setenv("MOKKA_RULE_5", `A client.test [client=10.0.0.0/8]/NOERROR("A 10.0.0.1 1")`)
+	os.Setenv("MOKKA_RULE_6", `A client.test [client=127.0.0.0/8,::1]/NOERROR("A 127.0.0.1 1")`)
+	os.Setenv("MOKKA_RULE_7", `A ecs.test [ecs=192.168.0.0/16]/NOERROR("A 192.168.0.1 1")`)
+	os.Setenv("MOKKA_RULE_8", `A ecs.test/NOERROR("A 1.1.1.1 1")`)
+	os.Setenv("MOKKA_RULE_9", `A exact.test [match=exact]/NOERROR("A 1.2.3.9 1")`)
+	os.Setenv("MOKKA_RULE_A", `* multi.test [match=exact]/NOERROR("A 1.2.3.4 1", "AAAA 2001:db8::1 1", "MX 10 mail.test. 1")`)
+	os.Setenv("MOKKA_RULE_B1", `* alias.test [match=exact]/NOERROR("CNAME alias2.test. 1")`)
+	os.Setenv("MOKKA_RULE_B2", `* alias2.test [match=exact]/NOERROR("CNAME dest.test. 1")`)
+	os.Setenv("MOKKA_RULE_B3", `A dest.test [match=exact]/NOERROR("A 1.2.3.10 1")`)
+	os.Setenv("MOKKA_RULE_B4", `* loop1.test [match=exact]/NOERROR("CNAME loop2.test. 1")`)
+	os.Setenv("MOKKA_RULE_B5", `* loop2.test [match=exact]/NOERROR("CNAME loop1.test. 1")`)
+	os.Setenv("MOKKA_RULE_B6", `* deep0.test [match=exact]/NOERROR("CNAME deep1.test. 1")`)
+	os.Setenv("MOKKA_RULE_B7", `* deep1.test [match=exact]/NOERROR("CNAME deep2.test. 1")`)
+	os.Setenv("MOKKA_RULE_B8", `* deep2.test [match=exact]/NOERROR("CNAME deep3.test. 1")`)
+	os.Setenv("MOKKA_RULE_B9", `* deep3.test [match=exact]/NOERROR("CNAME deep4.test. 1")`)
+	os.Setenv("MOKKA_RULE_BA", `A deep4.test [match=exact]/NOERROR("A 1.2.3.11 1")`)
+	os.Setenv("MOKKA_RULE_F1", `A hmac.test [match=exact tsig=mokka-key]/NOERROR("A 192.0.2.1 1")`)
+	os.Setenv("MOKKA_RULE_F2", `A hmac.test [match=exact]/NOERROR("A 192.0.2.2 1")`)
+	os.Setenv("MOKKA_RULE_F3", `A bad1.test [match=exact tsigerror=badsig]/NOERROR("A 192.0.2.3 1")`)
+	os.Setenv("MOKKA_RULE_F4", `A bad2.test [match=exact tsigerror=badtime]/NOERROR("A 192.0.2.3 1")`)
+	os.Setenv("MOKKA_RULE_F5", `A bad3.test [match=exact tsigerror=badmac]/NOERROR("A 192.0.2.3 1")`)
+	os.Setenv("MOKKA_TSIG_KEYS", "mokka-key:hmac-sha256:"+tsigSecret)
+	os.Setenv("MOKKA_RULE_G1", `SOA notify.test [match=exact opcode=notify]/NOERROR()`)
+	os.Setenv("MOKKA_RULE_G2", `SOA refused.test [match=exact opcode=notify]/REFUSED()`)
+	os.Setenv("MOKKA_DNSSEC_ZONES", "dnssec.test,mokka.test")
+	os.Setenv("MOKKA_RULE_H1", `A valid.dnssec.test [match=exact]/NOERROR("A 192.0.2.1 300", "A 192.0.2.2 300")`)
+	os.Setenv("MOKKA_RULE_H2", `A expired.dnssec.test [match=exact dnssec=expired]/NOERROR("A 192.0.2.1 300")`)
+	os.Setenv("MOKKA_RULE_H3", `A notyetvalid.dnssec.test [match=exact dnssec=notyetvalid]/NOERROR("A 192.0.2.1 300")`)
+	os.Setenv("MOKKA_RULE_H4", `A broken.dnssec.test [match=exact dnssec=bogus]/NOERROR("A 192.0.2.1 300")`)
+	os.Setenv("MOKKA_RULE_H5", `A plain.dnssec.test [match=exact dnssec=unsigned]/NOERROR("A 192.0.2.1 300")`)
+	os.Setenv("MOKKA_RULE_I1", `A drop.test [match=exact]/DROP()`)
+	os.Setenv("MOKKA_RULE_I2", `SOA drop.test [match=exact opcode=update]/DROP()`)
+	os.Setenv("MOKKA_RULE_I3", `SOA drop.test [match=exact opcode=notify]/DROP()`)
+	os.Setenv("MOKKA_RULE_I4", `AXFR,IXFR drop.test [match=exact]/DROP()`)
+	os.Setenv("MOKKA_RULE_I5", `* forward.test [match=exact]/FORWARD("127.0.0.1:1")`)
+	os.Setenv("MOKKA_RULE_J1", `TXT large.test [match=exact]/NOERROR(`+largeRecords()+`)`)
+	os.Setenv("MOKKA_DEFAULT_RESPONSE_SRV", `REFUSED()`)
+	os.Setenv("MOKKA_DEFAULT_RESPONSE_NAPTR", `DROP()`)
+	os.Setenv("MOKKA_ADMIN_LISTEN_ADDRESS", "127.0.0.1:55080")
+	os.Setenv("MOKKA_NOTIFY_TARGETS", "127.0.0.1"+address)
+	os.Setenv("MOKKA_FOLLOW_CNAME", "true")
+	os.Setenv("MOKKA_FOLLOW_CNAME_MAX_DEPTH", "3")
+	os.Setenv("MOKKA_DOT_LISTEN_ADDRESS", dotAddress)
+	os.Setenv("MOKKA_DOH_LISTEN_ADDRESS", dohAddress)
+	os.Setenv("MOKKA_DOQ_LISTEN_ADDRESS", doqAddress)
+
+	tmpDir, err := os.MkdirTemp("", "mokka")
+	Expect(err).Should(Succeed())
+	DeferCleanup(os.RemoveAll, tmpDir)
+
+	certFile = filepath.Join(tmpDir, "cert.pem")
+	os.Setenv("MOKKA_TLS_GENERATED_CERT_FILE", certFile)
+
+	queryLogFile = filepath.Join(tmpDir, "queries.log")
+	os.Setenv("MOKKA_QUERY_LOG_TARGET", queryLogFile)
+
+	dnstapSocket := filepath.Join(tmpDir, "dnstap.sock")
+	dnstapInput, err := dnstap.NewFrameStreamSockInputFromPath(dnstapSocket)
+	Expect(err).Should(Succeed())
+
+	dnstapFrames = make(chan []byte, 100)
+	go dnstapInput.ReadInto(dnstapFrames)
+
+	os.Setenv("MOKKA_DNSTAP_TARGET", "unix:"+dnstapSocket)
+	os.Setenv("MOKKA_DNSTAP_IDENTITY", "mokka-test")
+
+	pcapFile = filepath.Join(tmpDir, "mokka.pcap")
+	os.Setenv("MOKKA_PCAP_FILE", pcapFile)
+
+	zoneV1 := filepath.Join(tmpDir, "example.test.v1.zone")
+	Expect(os.WriteFile(zoneV1, []byte(testZone(1, 10)), 0o600)).Should(Succeed())
+
+	zoneV2 := filepath.Join(tmpDir, "example.test.v2.zone")
+	Expect(os.WriteFile(zoneV2, []byte(testZone(2, 1000)), 0o600)).Should(Succeed())
+
+	authZone := filepath.Join(tmpDir, "mokka.test.zone")
+	Expect(os.WriteFile(authZone, []byte(authoritativeZone), 0o600)).Should(Succeed())
+
+	os.Setenv("MOKKA_RULE_D1", fmt.Sprintf(`* mokka.test [match=suffix]/ZONE("%s")`, authZone))
+	ddnsZone := filepath.Join(tmpDir, "ddns.test.zone")
+	Expect(os.WriteFile(ddnsZone, []byte(updateZone), 0o600)).Should(Succeed())
+
+	os.Setenv("MOKKA_RULE_E1", fmt.Sprintf(`SOA ddns.test [match=exact opcode=update]/ZONE("%s")`, ddnsZone))
+	os.Setenv("MOKKA_RULE_E2", fmt.Sprintf(`* ddns.test [match=suffix]/ZONE("%s")`, ddnsZone))
+	os.Setenv("MOKKA_RULE_E3", `SOA refused.test [match=exact opcode=update]/REFUSED()`)
+	os.Setenv("MOKKA_RULE_C1", fmt.Sprintf(`AXFR,IXFR example.test [match=exact]/TRANSFER("%s", "%s")`, zoneV1, zoneV2))
+	os.Setenv("MOKKA_RULE_C2", `AXFR,IXFR rule.test [match=exact]/NOERROR("SOA ns1.rule.test. hostmaster.rule.test. 7 3600 600 86400 300 300", "A 192.0.2.1 300")`)
+	os.Setenv("MOKKA_RULE_C3", fmt.Sprintf(`AXFR,IXFR wrong.test [match=exact]/TRANSFER("%s")`, zoneV1))
 
 	cfg, err := config.ReadConfig()
 	Expect(err).Should(Succeed())
@@ -46,6 +156,30 @@ var _ = BeforeSuite(func() {
 		msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
 		_, err := requestServer(msg, "udp")
 
+		if err != nil {
+			return err
+		}
+
+		_, err = requestServer(msg, "tcp-tls")
+		if err != nil {
+			return err
+		}
+
+		_, err = requestServer(msg, "https")
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.Get(adminURL + notifyPath)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		msg.Id = 0
+		_, err = requestServer(msg, "quic")
+
 		return err
 	}, "5s").Should(BeNil())
 	DeferCleanup(sut.Stop)
@@ -120,6 +254,237 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	When("rule has client restrictions", func() {
+		It("should match the client address", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("client.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("client.test.", dns.TypeA, 1, "127.0.0.1"))
+		})
+
+		It("should match the EDNS client subnet", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("ecs.test"), dns.TypeA)
+			msg.SetEdns0(dns.DefaultMsgSize, false)
+			msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        1,
+				SourceNetmask: 24,
+				Address:       net.ParseIP("192.168.1.0").To4(),
+			})
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("ecs.test.", dns.TypeA, 1, "192.168.0.1"))
+		})
+
+		It("should skip the rule without EDNS client subnet", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("ecs.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("ecs.test.", dns.TypeA, 1, "1.1.1.1"))
+		})
+	})
+
+	When("rule uses exact matcher", func() {
+		It("should match the exact name", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("EXACT.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("EXACT.test.", dns.TypeA, 1, "1.2.3.9"))
+		})
+
+		It("should not match other names containing the pattern", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("notexact.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+		})
+	})
+
+	When("rule applies to all types", func() {
+		It("should return only records of the query type", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("multi.test"), dns.TypeAAAA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(HaveLen(1))
+			Expect(resp.Answer).Should(BeDNSRecord("multi.test.", dns.TypeAAAA, 1, "2001:db8::1"))
+		})
+
+		It("should return all records for ANY query", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("multi.test"), dns.TypeANY)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(HaveLen(3))
+			Expect(resp.Answer[2]).Should(BeDNSRecord("multi.test.", dns.TypeMX, 1, "mail.test."))
+		})
+
+		It("should return empty answer if no record has the query type", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("multi.test"), dns.TypeTXT)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Answer).Should(BeEmpty())
+		})
+	})
+
+	When("answer contains CNAME", func() {
+		It("should follow the CNAME chain", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("alias.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Answer).Should(HaveLen(3))
+			Expect(resp.Answer[0].(*dns.CNAME).Target).Should(Equal("alias2.test."))
+			Expect(resp.Answer[1].(*dns.CNAME).Target).Should(Equal("dest.test."))
+			Expect(resp.Answer[2]).Should(BeDNSRecord("dest.test.", dns.TypeA, 1, "1.2.3.10"))
+		})
+
+		It("should return NXDOMAIN if the target is unknown", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("alias.test"), dns.TypeAAAA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+			Expect(resp.Answer).Should(HaveLen(2))
+		})
+
+		It("should not follow the CNAME for CNAME queries", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("alias.test"), dns.TypeCNAME)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(HaveLen(1))
+		})
+
+		It("should detect loops", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("loop1.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeServerFailure))
+			Expect(resp.Answer).Should(HaveLen(2))
+		})
+
+		It("should follow a chain up to the max depth", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("deep1.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Answer).Should(HaveLen(4))
+			Expect(resp.Answer[3]).Should(BeDNSRecord("deep4.test.", dns.TypeA, 1, "1.2.3.11"))
+		})
+
+		It("should return SERVFAIL if the chain exceeds the max depth", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("deep0.test"), dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeServerFailure))
+			Expect(resp.Answer).Should(HaveLen(4))
+			Expect(resp.Answer[3].(*dns.CNAME).Target).Should(Equal("deep4.test."))
+		})
+	})
+
+	When("query has no question", func() {
+		It("should return FORMERR", func() {
+			msg := new(dns.Msg)
+			msg.Id = dns.Id()
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeFormatError))
+			Expect(resp.Id).Should(Equal(msg.Id))
+		})
+	})
+
+	When("query has multiple questions", func() {
+		var msg *dns.Msg
+
+		BeforeEach(func() {
+			msg = new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
+			msg.Question = append(msg.Question, dns.Question{Name: "exact.test.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		})
+
+		// requests a separate server with the multi question mode, the suite server isn't changed
+		requestMode := func(mode string) *dns.Msg {
+			const modeAddress = "127.0.0.1:55561"
+
+			os.Clearenv()
+			os.Setenv("MOKKA_LISTENERS", "udp://"+modeAddress)
+			os.Setenv("MOKKA_MULTI_QUESTION", mode)
+			os.Setenv("MOKKA_RULE_1", `A google/NOERROR("A 1.2.3.4 123")`)
+			os.Setenv("MOKKA_RULE_2", `A exact.test [match=exact]/NOERROR("A 1.2.3.9 1")`)
+			DeferCleanup(os.Clearenv)
+
+			cfg, err := config.ReadConfig()
+			Expect(err).Should(Succeed())
+
+			srv, err := NewServer(cfg)
+			Expect(err).Should(Succeed())
+
+			srv.Start()
+			DeferCleanup(srv.Stop)
+
+			var resp *dns.Msg
+
+			Eventually(func() error {
+				resp, _, err = (&dns.Client{Timeout: time.Second}).Exchange(msg, modeAddress)
+
+				return err
+			}, "5s").Should(Succeed())
+
+			return resp
+		}
+
+		It("should return FORMERR by default", func() {
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeFormatError))
+		})
+
+		It("should answer the first question", func() {
+			resp := requestMode(config.MultiQuestionFirst)
+
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Question).Should(HaveLen(1))
+			Expect(resp.Answer).Should(HaveLen(1))
+			Expect(resp.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
+		})
+
+		It("should answer all questions", func() {
+			resp := requestMode(config.MultiQuestionAll)
+
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Question).Should(HaveLen(2))
+			Expect(resp.Answer).Should(HaveLen(2))
+			Expect(resp.Answer[1]).Should(BeDNSRecord("exact.test.", dns.TypeA, 1, "1.2.3.9"))
+		})
+	})
+
 	When("DNS request is performed over TCP", func() {
 		It("should return expected result", func() {
 			msg := new(dns.Msg)
@@ -130,8 +495,139 @@ var _ = Describe("Server", func() {
 			Expect(resp.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
 		})
 	})
+
+	When("DNS request is performed over HTTPS", func() {
+		It("should return expected result", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
+
+			resp, err := requestServer(msg, "https")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
+		})
+
+		It("should not truncate a large answer to the EDNS udp size", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("large.test."), dns.TypeTXT)
+			msg.SetEdns0(1232, false)
+
+			resp, err := requestServer(msg, "https")
+			Expect(err).Should(Succeed())
+			Expect(resp.Truncated).Should(BeFalse())
+			Expect(resp.Answer).Should(HaveLen(largeRecordCount))
+		})
+	})
+
+	When("DNS request is performed over QUIC", func() {
+		It("should return expected result", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
+			msg.Id = 0
+
+			resp, err := requestServer(msg, "quic")
+			Expect(err).Should(Succeed())
+			Expect(resp.Id).Should(BeZero())
+			Expect(resp.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
+		})
+
+		It("should not truncate a large answer to the EDNS udp size", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("large.test."), dns.TypeTXT)
+			msg.SetEdns0(1232, false)
+			msg.Id = 0
+
+			resp, err := requestServer(msg, "quic")
+			Expect(err).Should(Succeed())
+			Expect(resp.Truncated).Should(BeFalse())
+			Expect(resp.Answer).Should(HaveLen(largeRecordCount))
+		})
+
+		It("should close the connection if message ID is not 0", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
+			msg.Id = 1
+
+			_, err := requestServer(msg, "quic")
+			Expect(err).Should(HaveOccurred())
+
+			var appErr *quic.ApplicationError
+			Expect(errors.As(err, &appErr)).Should(BeTrue())
+			Expect(appErr.ErrorCode).Should(Equal(doqProtocolError))
+		})
+	})
+
+	When("DNS request is performed over TLS", func() {
+		It("should return expected result", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn("google.de."), dns.TypeA)
+
+			resp, err := requestServer(msg, "tcp-tls")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(BeDNSRecord("google.de.", dns.TypeA, 123, "1.2.3.4"))
+		})
+	})
 })
 
+// largeRecordCount is the number of TXT records of large.test., together they exceed 1232 bytes
+const largeRecordCount = 8
+
+// largeRecords returns the records of large.test. as arguments of NOERROR
+func largeRecords() string {
+	records := make([]string, largeRecordCount)
+	for i := range records {
+		records[i] = fmt.Sprintf(`"TXT %d%s 1"`, i, strings.Repeat("x", 250))
+	}
+
+	return strings.Join(records, ", ")
+}
+
+// trusts the generated self-signed certificate
+func clientTLSConfig() *tls.Config {
+	certPEM, err := os.ReadFile(certFile)
+	Expect(err).Should(Succeed())
+
+	pool := x509.NewCertPool()
+	Expect(pool.AppendCertsFromPEM(certPEM)).Should(BeTrue())
+
+	return &tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+func httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: clientTLSConfig()},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func requestDoQ(request *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tlsConfig := clientTLSConfig()
+	tlsConfig.NextProtos = []string{"doq"}
+
+	conn, err := quic.DialAddr(ctx, doqAddress, tlsConfig, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to server: %w", err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not open stream: %w", err)
+	}
+
+	if err := writeDoQMessage(stream, request); err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	return readDoQMessage(stream)
+}
+
 func requestServer(request *dns.Msg, network string) (*dns.Msg, error) {
 	var response *dns.Msg
 
@@ -172,6 +668,36 @@ func requestServer(request *dns.Msg, network string) (*dns.Msg, error) {
 			return nil, fmt.Errorf("could not exchange request: %w", err)
 		}
 		response = resp
+	case "tcp-tls":
+		c := dns.Client{Net: "tcp-tls", TLSConfig: clientTLSConfig()}
+		resp, _, err := c.Exchange(request, dotAddress)
+		if err != nil {
+			return nil, fmt.Errorf("could not exchange request: %w", err)
+		}
+		response = resp
+	case "https":
+		msg, err := request.Pack()
+		if err != nil {
+			return nil, fmt.Errorf("can't pack request: %w", err)
+		}
+
+		resp, err := httpClient().Post("https://"+dohAddress+"/dns-query", "application/dns-message", bytes.NewReader(msg))
+		if err != nil {
+			return nil, fmt.Errorf("could not send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not read response: %w", err)
+		}
+
+		response = new(dns.Msg)
+		if err := response.Unpack(body); err != nil {
+			return nil, fmt.Errorf("can't unpack response: %w", err)
+		}
+	case "quic":
+		return requestDoQ(request)
 	}
 
 	return response, nil
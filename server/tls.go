@@ -0,0 +1,124 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	certValidity   = 365 * 24 * time.Hour
+	serialBitSize  = 128
+	certFilePerm   = 0o644
+	selfSignedName = "dns-mokka"
+)
+
+// createTLSConfig loads the configured certificate or generates a self-signed one
+func createTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if cfg.CertFile != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load certificate: %w", err)
+		}
+	} else {
+		var certPEM []byte
+
+		cert, certPEM, err = generateSelfSignedCert()
+		if err != nil {
+			return nil, fmt.Errorf("can't generate self-signed certificate: %w", err)
+		}
+
+		if err := publishCert(cert, certPEM, cfg.GeneratedCertFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// generateSelfSignedCert creates an ECDSA certificate for localhost, returns also the PEM encoded certificate
+func generateSelfSignedCert() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialBitSize))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: selfSignedName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost", selfSignedName},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+
+	return cert, certPEM, err
+}
+
+// publishCert logs the generated certificate and writes it to the file (if defined), so clients can trust it
+func publishCert(cert tls.Certificate, certPEM []byte, file string) error {
+	fingerprint := sha256.Sum256(cert.Certificate[0])
+
+	log.Infof("generated self-signed certificate, SHA-256 fingerprint: %s", hex.EncodeToString(fingerprint[:]))
+
+	if file == "" {
+		log.Infof("self-signed certificate:\n%s", certPEM)
+
+		return nil
+	}
+
+	if err := os.WriteFile(file, certPEM, certFilePerm); err != nil {
+		return fmt.Errorf("can't write generated certificate: %w", err)
+	}
+
+	log.Infof("self-signed certificate was written to '%s'", file)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "mokka-tls")
+		Expect(err).Should(Succeed())
+		DeferCleanup(os.RemoveAll, tmpDir)
+	})
+
+	When("no certificate is configured", func() {
+		It("should generate a self-signed certificate for localhost", func() {
+			certFile := filepath.Join(tmpDir, "generated.pem")
+
+			tlsConfig, err := createTLSConfig(config.TLSConfig{GeneratedCertFile: certFile})
+			Expect(err).Should(Succeed())
+			Expect(tlsConfig.Certificates).Should(HaveLen(1))
+
+			certPEM, err := os.ReadFile(certFile)
+			Expect(err).Should(Succeed())
+
+			block, _ := pem.Decode(certPEM)
+			Expect(block).ShouldNot(BeNil())
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			Expect(err).Should(Succeed())
+			Expect(cert.VerifyHostname("localhost")).Should(Succeed())
+			Expect(cert.VerifyHostname("127.0.0.1")).Should(Succeed())
+		})
+	})
+
+	When("certificate files are configured", func() {
+		It("should load the certificate", func() {
+			cert, certPEM, err := generateSelfSignedCert()
+			Expect(err).Should(Succeed())
+
+			keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+			Expect(err).Should(Succeed())
+
+			certFile := filepath.Join(tmpDir, "cert.pem")
+			keyFile := filepath.Join(tmpDir, "key.pem")
+			Expect(os.WriteFile(certFile, certPEM, 0o600)).Should(Succeed())
+			Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)).
+				Should(Succeed())
+
+			tlsConfig, err := createTLSConfig(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+			Expect(err).Should(Succeed())
+			Expect(tlsConfig.Certificates[0].Certificate[0]).Should(Equal(cert.Certificate[0]))
+		})
+
+		It("should fail if the files don't exist", func() {
+			_, err := createTLSConfig(config.TLSConfig{
+				CertFile: filepath.Join(tmpDir, "missing.pem"),
+				KeyFile:  filepath.Join(tmpDir, "missing.key"),
+			})
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("can't load certificate"))
+		})
+	})
+})
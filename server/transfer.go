@@ -0,0 +1,172 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// maximal size of the records in one message of a zone transfer stream
+const transferMessageSize = 16 * 1024
+
+// isTransfer returns true for AXFR and IXFR queries
+func isTransfer(qType uint16) bool {
+	return qType == dns.TypeAXFR || qType == dns.TypeIXFR
+}
+
+// handleTransfer answers AXFR and IXFR requests with the zone of the matching rule
+func (s *Server) handleTransfer(rw dns.ResponseWriter, request *dns.Msg, ctx *requestContext) {
+	records, rCode, drop := s.transferRecords(request, ctx)
+	if drop {
+		return
+	}
+
+	if rCode != dns.RcodeSuccess {
+		response := new(dns.Msg)
+		response.SetRcode(request, rCode)
+		s.writeResponse(rw, request, response, ctx)
+
+		return
+	}
+
+	if err := s.writeTransfer(rw, request, records, ctx); err != nil {
+		log.Error("can't write zone transfer: ", err)
+	}
+}
+
+// transferRecords returns the records of the zone transfer: the zone files of the rule or the records of the rule.
+// drop is set if the rule doesn't answer (DROP()).
+func (s *Server) transferRecords(request *dns.Msg, ctx *requestContext) (records []dns.RR, rCode int, drop bool) {
+	question := request.Question[0]
+
+	rule := s.matchRule(question, ctx)
+	if rule == nil {
+		return nil, dns.RcodeNameError, false
+	}
+
+	result := s.executeRule(rule)
+	if result.Drop {
+		return nil, 0, true
+	}
+
+	// zone transfers aren't forwarded, only rules with type "*" can get here (see config.checkFunction)
+	if result.Forward != nil {
+		return nil, dns.RcodeNotImplemented, false
+	}
+
+	if result.RCode != dns.RcodeSuccess {
+		return nil, result.RCode, false
+	}
+
+	if len(result.ZoneFiles) == 0 {
+		return ruleTransfer(createAnswers(question.Name, result.RR)), dns.RcodeSuccess, false
+	}
+
+	history, err := s.zones.history(result.ZoneFiles)
+	if err != nil {
+		log.Errorf("can't load zone of rule '%s': %v", rule.Name, err)
+
+		return nil, dns.RcodeServerFailure, false
+	}
+
+	current := history.Current()
+
+	if !strings.EqualFold(dns.Fqdn(question.Name), current.Origin) {
+		return nil, dns.RcodeNotAuth, false
+	}
+
+	if serial, ok := requestSerial(request); ok && question.Qtype == dns.TypeIXFR {
+		return history.IXFR(serial), dns.RcodeSuccess, false
+	}
+
+	return current.AXFR(), dns.RcodeSuccess, false
+}
+
+// ruleTransfer orders the records of a rule like a full zone transfer: SOA, all other records and SOA again.
+// The records are returned unchanged if there is no SOA record.
+func ruleTransfer(answers []dns.RR) []dns.RR {
+	var (
+		soa     dns.RR
+		records []dns.RR
+	)
+
+	for _, a := range answers {
+		if a.Header().Rrtype == dns.TypeSOA && soa == nil {
+			soa = a
+
+			continue
+		}
+
+		records = append(records, a)
+	}
+
+	if soa == nil {
+		return answers
+	}
+
+	return append(append([]dns.RR{soa}, records...), soa)
+}
+
+// requestSerial returns the serial of the SOA record in the authority section of an IXFR request
+func requestSerial(request *dns.Msg) (uint32, bool) {
+	for _, rr := range request.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+
+	return 0, false
+}
+
+// writeTransfer writes the records as a stream of messages over TCP or as one message over other transports.
+// A UDP response which doesn't fit contains only the first SOA record, so the client retries over TCP (RFC 1995).
+// The messages of a signed request are signed.
+func (s *Server) writeTransfer(rw dns.ResponseWriter, request *dns.Msg, records []dns.RR, ctx *requestContext) error {
+	_, buffered := rw.(*bufferedResponseWriter)
+	network := transportNetwork(rw)
+
+	if buffered || network != "tcp" {
+		response := new(dns.Msg)
+		response.SetReply(request)
+		response.Authoritative = true
+		response.Compress = true
+		response.Answer = records
+
+		if network != "tcp" && response.Len() > getMaxResponseSize(network, request) {
+			response.Answer = records[:1]
+		}
+
+		s.writeResponse(rw, request, response, ctx)
+
+		return nil
+	}
+
+	envelopes := make(chan *dns.Envelope, len(records))
+
+	var (
+		chunk []dns.RR
+		size  int
+	)
+
+	for _, rr := range records {
+		if size+dns.Len(rr) > transferMessageSize && len(chunk) > 0 {
+			envelopes <- &dns.Envelope{RR: chunk}
+			chunk, size = nil, 0
+		}
+
+		chunk = append(chunk, rr)
+		size += dns.Len(rr)
+	}
+
+	envelopes <- &dns.Envelope{RR: chunk}
+
+	close(envelopes)
+
+	// the stream is recorded as one response
+	ctx.response = new(dns.Msg)
+	ctx.response.SetReply(request)
+	ctx.response.Answer = records
+
+	return new(dns.Transfer).Out(rw, request, envelopes)
+}
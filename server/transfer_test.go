@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// testZone returns the master file of example.test. with the serial and the count of host records
+func testZone(serial, hosts int) string {
+	var sb strings.Builder
+
+	sb.WriteString("$ORIGIN example.test.\n$TTL 300\n")
+	sb.WriteString(fmt.Sprintf("@ IN SOA ns1 hostmaster %d 3600 600 86400 300\n", serial))
+	sb.WriteString("@ IN NS ns1\nns1 IN A 192.0.2.1\n")
+
+	for i := 0; i < hosts; i++ {
+		sb.WriteString(fmt.Sprintf("host%d IN A 198.51.%d.%d\n", i, i/256, i%256))
+	}
+
+	return sb.String()
+}
+
+// transferIn performs a zone transfer over TCP and returns the received messages
+func transferIn(request *dns.Msg) ([][]dns.RR, error) {
+	tr := new(dns.Transfer)
+
+	envelopes, err := tr.In(request, "127.0.0.1"+address)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages [][]dns.RR
+
+	for e := range envelopes {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		messages = append(messages, e.RR)
+	}
+
+	return messages, nil
+}
+
+func soaSerial(rr dns.RR) uint32 {
+	return rr.(*dns.SOA).Serial
+}
+
+var _ = Describe("Zone transfer", func() {
+	When("AXFR is requested over TCP", func() {
+		It("should stream the current zone version in multiple messages", func() {
+			msg := new(dns.Msg)
+			msg.SetAxfr("example.test.")
+
+			messages, err := transferIn(msg)
+			Expect(err).Should(Succeed())
+			Expect(len(messages)).Should(BeNumerically(">", 1))
+
+			var records []dns.RR
+			for _, m := range messages {
+				records = append(records, m...)
+			}
+
+			Expect(records).Should(HaveLen(1004))
+			Expect(soaSerial(records[0])).Should(BeNumerically("==", 2))
+			Expect(soaSerial(records[len(records)-1])).Should(BeNumerically("==", 2))
+		})
+
+		It("should build the zone from the records of the rule", func() {
+			msg := new(dns.Msg)
+			msg.SetAxfr("rule.test.")
+
+			messages, err := transferIn(msg)
+			Expect(err).Should(Succeed())
+			Expect(messages).Should(HaveLen(1))
+			Expect(messages[0]).Should(HaveLen(3))
+			Expect(soaSerial(messages[0][0])).Should(BeNumerically("==", 7))
+			Expect(messages[0][1]).Should(BeDNSRecord("rule.test.", dns.TypeA, 300, "192.0.2.1"))
+			Expect(soaSerial(messages[0][2])).Should(BeNumerically("==", 7))
+		})
+
+		It("should return NOTAUTH if the zone has another origin", func() {
+			msg := new(dns.Msg)
+			msg.SetAxfr("wrong.test.")
+
+			resp, err := requestServer(msg, "tcp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+		})
+
+		It("should return NXDOMAIN if no rule matches", func() {
+			msg := new(dns.Msg)
+			msg.SetAxfr("unknown.test.")
+
+			resp, err := requestServer(msg, "tcp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+		})
+
+		It("should return NOTIMP if the rule forwards", func() {
+			msg := new(dns.Msg)
+			msg.SetAxfr("forward.test.")
+
+			resp, err := requestServer(msg, "tcp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotImplemented))
+		})
+	})
+
+	When("IXFR is requested", func() {
+		It("should return the differences since the serial of the client", func() {
+			msg := new(dns.Msg)
+			msg.SetIxfr("example.test.", 1, "ns1.example.test.", "hostmaster.example.test.")
+
+			messages, err := transferIn(msg)
+			Expect(err).Should(Succeed())
+
+			var records []dns.RR
+			for _, m := range messages {
+				records = append(records, m...)
+			}
+
+			// current SOA, old SOA, new SOA, 990 added hosts, current SOA
+			Expect(records).Should(HaveLen(994))
+			Expect(soaSerial(records[0])).Should(BeNumerically("==", 2))
+			Expect(soaSerial(records[1])).Should(BeNumerically("==", 1))
+			Expect(soaSerial(records[2])).Should(BeNumerically("==", 2))
+			Expect(soaSerial(records[len(records)-1])).Should(BeNumerically("==", 2))
+		})
+
+		It("should return only the SOA record if the client is up to date", func() {
+			msg := new(dns.Msg)
+			msg.SetIxfr("example.test.", 2, "ns1.example.test.", "hostmaster.example.test.")
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(HaveLen(1))
+			Expect(soaSerial(resp.Answer[0])).Should(BeNumerically("==", 2))
+		})
+
+		It("should return only the SOA record over UDP if the response doesn't fit", func() {
+			msg := new(dns.Msg)
+			msg.SetIxfr("example.test.", 1, "ns1.example.test.", "hostmaster.example.test.")
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.Answer).Should(HaveLen(1))
+			Expect(soaSerial(resp.Answer[0])).Should(BeNumerically("==", 2))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+)
+
+// transport is a listener of one protocol, all transports pass the requests to OnRequest
+type transport interface {
+	ListenAndServe() error
+	Shutdown() error
+	fmt.Stringer
+}
+
+// dnsTransport wraps the miekg server (UDP, TCP and DoT)
+type dnsTransport struct {
+	*dns.Server
+	listener config.Listener
+}
+
+// ListenAndServe starts the server, stream listeners are wrapped to read the PROXY protocol header if enabled
+func (t *dnsTransport) ListenAndServe() error {
+	if !t.listener.ProxyProtocol {
+		return t.Server.ListenAndServe()
+	}
+
+	ln, err := net.Listen(t.listener.Network(), t.listener.Address)
+	if err != nil {
+		return err
+	}
+
+	ln = newProxyListener(ln)
+
+	if t.listener.Protocol == config.ProtocolDoT {
+		ln = tls.NewListener(ln, t.Server.TLSConfig)
+	}
+
+	t.Server.Listener = ln
+
+	return t.Server.ActivateAndServe()
+}
+
+func (t *dnsTransport) String() string {
+	return t.listener.String()
+}
+
+func (s *Server) createTransport(l config.Listener) (transport, error) {
+	if l.Encrypted() && s.tlsConfig == nil {
+		tlsConfig, err := createTLSConfig(s.cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("can't create TLS config: %w", err)
+		}
+
+		s.tlsConfig = tlsConfig
+	}
+
+	switch l.Protocol {
+	case config.ProtocolUDP, config.ProtocolTCP, config.ProtocolDoT:
+		return &dnsTransport{Server: s.createDNSServer(l), listener: l}, nil
+	case config.ProtocolDoH:
+		return s.createDoHServer(l), nil
+	case config.ProtocolDoQ:
+		return createDoQServer(l, s.tlsConfig, s.handler(l)), nil
+	}
+
+	return nil, fmt.Errorf("unknown protocol '%s'", l.Protocol)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	When("only UDP listener is configured", func() {
+		const udpOnlyAddress = "127.0.0.1:55556"
+
+		BeforeEach(func() {
+			srv, err := NewServer(&config.Config{
+				Listeners: []config.Listener{{Protocol: config.ProtocolUDP, IPVersion: "4", Address: udpOnlyAddress}},
+			})
+			Expect(err).Should(Succeed())
+
+			srv.Start()
+			DeferCleanup(srv.Stop)
+		})
+
+		It("should answer UDP but not TCP requests", func() {
+			msg := new(dns.Msg)
+			msg.SetQuestion("example.com.", dns.TypeA)
+
+			Eventually(func() error {
+				_, _, err := (&dns.Client{Net: "udp"}).Exchange(msg, udpOnlyAddress)
+
+				return err
+			}, "5s").Should(Succeed())
+
+			_, err := net.DialTimeout("tcp", udpOnlyAddress, time.Second)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	When("listeners have views", func() {
+		const (
+			primaryAddress   = "127.0.0.1:55557"
+			secondaryAddress = "127.0.0.1:55558"
+		)
+
+		BeforeEach(func() {
+			os.Clearenv()
+			os.Setenv("MOKKA_LISTENERS", "udp://"+primaryAddress+"?view=primary,udp://"+secondaryAddress+"?view=secondary")
+			os.Setenv("MOKKA_RULE_1", `A example.com [view=primary]/NOERROR("A 1.1.1.1 1")`)
+			os.Setenv("MOKKA_RULE_2", `A example.com [view=secondary]/NOERROR("A 2.2.2.2 1")`)
+			os.Setenv("MOKKA_RULE_3", `A common.com/NOERROR("A 3.3.3.3 1")`)
+			DeferCleanup(os.Clearenv)
+
+			cfg, err := config.ReadConfig()
+			Expect(err).Should(Succeed())
+
+			srv, err := NewServer(cfg)
+			Expect(err).Should(Succeed())
+
+			srv.Start()
+			DeferCleanup(srv.Stop)
+		})
+
+		It("should answer with the rules of the view", func() {
+			exchange := func(name, address string) *dns.Msg {
+				msg := new(dns.Msg)
+				msg.SetQuestion(name, dns.TypeA)
+
+				var resp *dns.Msg
+
+				Eventually(func() (err error) {
+					resp, _, err = (&dns.Client{Net: "udp"}).Exchange(msg, address)
+
+					return err
+				}, "5s").Should(Succeed())
+
+				return resp
+			}
+
+			Expect(exchange("example.com.", primaryAddress).Answer).Should(BeDNSRecord("example.com.", dns.TypeA, 1, "1.1.1.1"))
+			Expect(exchange("example.com.", secondaryAddress).Answer).Should(BeDNSRecord("example.com.", dns.TypeA, 1, "2.2.2.2"))
+			Expect(exchange("common.com.", primaryAddress).Answer).Should(BeDNSRecord("common.com.", dns.TypeA, 1, "3.3.3.3"))
+			Expect(exchange("common.com.", secondaryAddress).Answer).Should(BeDNSRecord("common.com.", dns.TypeA, 1, "3.3.3.3"))
+		})
+	})
+
+	When("protocol is unknown", func() {
+		It("should fail", func() {
+			_, err := NewServer(&config.Config{
+				Listeners: []config.Listener{{Protocol: "sctp", Address: ":53"}},
+			})
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("unknown protocol 'sctp'"))
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+var errTSIGNotSupported = errors.New("TSIG is not supported by the transport")
+
+// tsigSecrets returns the secrets by key name, the map is never nil, so signed requests are always verified
+func tsigSecrets(keys []config.TSIGKey) map[string]string {
+	secrets := make(map[string]string, len(keys))
+
+	for _, k := range keys {
+		secrets[k.Name] = k.Secret
+	}
+
+	return secrets
+}
+
+// verifyTSIG returns the name of the key of a signed and verified request or the TSIG error
+// if the verification failed. Both are empty for unsigned requests.
+func (s *Server) verifyTSIG(rw dns.ResponseWriter, request *dns.Msg) (keyName string, tsigError uint16) {
+	t := request.IsTsig()
+	if t == nil {
+		return "", dns.RcodeSuccess
+	}
+
+	if err := rw.TsigStatus(); err != nil {
+		log.Debugf("TSIG verification of key '%s' failed: %v", t.Hdr.Name, err)
+
+		switch {
+		case errors.Is(err, dns.ErrSecret):
+			return "", dns.RcodeBadKey
+		case errors.Is(err, dns.ErrTime):
+			return "", dns.RcodeBadTime
+		default:
+			return "", dns.RcodeBadSig
+		}
+	}
+
+	key, ok := s.tsigKey(t.Hdr.Name)
+	if !ok || key.Algorithm != dns.CanonicalName(t.Algorithm) {
+		return "", dns.RcodeBadKey
+	}
+
+	return key.Name, dns.RcodeSuccess
+}
+
+func (s *Server) tsigKey(name string) (config.TSIGKey, bool) {
+	for _, k := range s.cfg.TSIGKeys {
+		if k.Name == dns.CanonicalName(name) {
+			return k, true
+		}
+	}
+
+	return config.TSIGKey{}, false
+}
+
+// ruleTSIGError returns the TSIG error code of the "tsigerror" rule option
+func ruleTSIGError(rule *config.Rule) uint16 {
+	if rule == nil {
+		return dns.RcodeSuccess
+	}
+
+	switch rule.TSIGError {
+	case config.TSIGBadSig:
+		return dns.RcodeBadSig
+	case config.TSIGBadKey:
+		return dns.RcodeBadKey
+	case config.TSIGBadTime:
+		return dns.RcodeBadTime
+	}
+
+	return dns.RcodeSuccess
+}
+
+// writeResponse writes the response, the response of a signed request is signed with the same key (RFC 8945).
+// A failed verification or a deliberate TSIG error of the rule is returned as TSIG error.
+func (s *Server) writeResponse(rw dns.ResponseWriter, request, response *dns.Msg, ctx *requestContext) {
+	ctx.response = response
+
+	if t := request.IsTsig(); t != nil {
+		tsigError := ctx.tsigError
+		if tsigError == dns.RcodeSuccess {
+			tsigError = ruleTSIGError(ctx.rule)
+		}
+
+		now := time.Now().Unix()
+
+		response.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, now)
+
+		rt := response.IsTsig()
+		rt.Error = tsigError
+
+		if tsigError == dns.RcodeBadTime {
+			// the client can calculate the clock skew from the server time in the other data
+			rt.TimeSigned = t.TimeSigned
+			rt.OtherData = fmt.Sprintf("%012x", now)
+			rt.OtherLen = 6
+		}
+
+		if tsigError == dns.RcodeSuccess && ctx.rule != nil && ctx.rule.TSIGError == config.TSIGBadMAC {
+			s.writeBadMAC(rw, response, t)
+
+			return
+		}
+	}
+
+	if err := rw.WriteMsg(response); err != nil {
+		log.Error("can't write response: ", err)
+	}
+}
+
+// writeBadMAC signs the response with an inverted secret, so the MAC is wrong
+func (s *Server) writeBadMAC(rw dns.ResponseWriter, response *dns.Msg, requestTSIG *dns.TSIG) {
+	key, _ := s.tsigKey(requestTSIG.Hdr.Name)
+
+	secret, err := base64.StdEncoding.DecodeString(key.Secret)
+	if err != nil {
+		log.Error("can't decode TSIG secret: ", err)
+
+		return
+	}
+
+	for i := range secret {
+		secret[i] ^= 0xFF
+	}
+
+	data, _, err := dns.TsigGenerate(response, base64.StdEncoding.EncodeToString(secret), requestTSIG.MAC, false)
+	if err != nil {
+		log.Error("can't sign response: ", err)
+
+		return
+	}
+
+	if _, err := rw.Write(data); err != nil {
+		log.Error("can't write response: ", err)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	tsigKeyName = "mokka-key."
+	tsigSecret  = "c2VjcmV0LW9mLW1va2thLWtleS1mb3ItdGVzdHMhIQ=="
+	wrongSecret = "d3Jvbmctc2VjcmV0LW9mLW1va2thLWtleS10ZXN0cw=="
+)
+
+// requestSigned signs the request with the key and verifies the signature of the response
+func requestSigned(request *dns.Msg, keyName, secret string) (*dns.Msg, error) {
+	request.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+
+	c := dns.Client{Net: "udp", TsigSecret: map[string]string{keyName: secret}}
+	resp, _, err := c.Exchange(request, "127.0.0.1"+address)
+
+	return resp, err
+}
+
+var _ = Describe("TSIG", func() {
+	var msg *dns.Msg
+
+	BeforeEach(func() {
+		msg = new(dns.Msg)
+	})
+
+	When("request is signed with a configured key", func() {
+		It("should apply the rule for the key and sign the response", func() {
+			msg.SetQuestion("hmac.test.", dns.TypeA)
+
+			resp, err := requestSigned(msg, tsigKeyName, tsigSecret)
+			Expect(err).Should(Succeed())
+			Expect(resp.IsTsig()).ShouldNot(BeNil())
+			Expect(resp.Answer).Should(BeDNSRecord("hmac.test.", dns.TypeA, 1, "192.0.2.1"))
+		})
+
+		It("should sign zone transfers", func() {
+			msg.SetAxfr("example.test.")
+			msg.SetTsig(tsigKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+
+			tr := dns.Transfer{TsigSecret: map[string]string{tsigKeyName: tsigSecret}}
+
+			envelopes, err := tr.In(msg, "127.0.0.1"+address)
+			Expect(err).Should(Succeed())
+
+			for e := range envelopes {
+				Expect(e.Error).Should(Succeed())
+			}
+		})
+	})
+
+	When("request is not signed", func() {
+		It("should skip rules which require a key", func() {
+			msg.SetQuestion("hmac.test.", dns.TypeA)
+
+			resp, err := requestServer(msg, "udp")
+			Expect(err).Should(Succeed())
+			Expect(resp.IsTsig()).Should(BeNil())
+			Expect(resp.Answer).Should(BeDNSRecord("hmac.test.", dns.TypeA, 1, "192.0.2.2"))
+		})
+	})
+
+	When("signature is invalid", func() {
+		It("should return NOTAUTH with BADSIG", func() {
+			msg.SetQuestion("hmac.test.", dns.TypeA)
+
+			resp, err := requestSigned(msg, tsigKeyName, wrongSecret)
+			Expect(err).Should(HaveOccurred())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+			Expect(resp.IsTsig().Error).Should(Equal(uint16(dns.RcodeBadSig)))
+			Expect(resp.Answer).Should(BeEmpty())
+		})
+	})
+
+	When("key is unknown", func() {
+		It("should return NOTAUTH with BADKEY", func() {
+			msg.SetQuestion("hmac.test.", dns.TypeA)
+
+			resp, err := requestSigned(msg, "unknown-key.", tsigSecret)
+			Expect(err).Should(HaveOccurred())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+			Expect(resp.IsTsig().Error).Should(Equal(uint16(dns.RcodeBadKey)))
+		})
+	})
+
+	When("rule has a deliberate TSIG error", func() {
+		It("should return BADSIG", func() {
+			msg.SetQuestion("bad1.test.", dns.TypeA)
+
+			resp, err := requestSigned(msg, tsigKeyName, tsigSecret)
+			Expect(err).Should(HaveOccurred())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+			Expect(resp.IsTsig().Error).Should(Equal(uint16(dns.RcodeBadSig)))
+		})
+
+		It("should return a signed BADTIME with the server time", func() {
+			msg.SetQuestion("bad2.test.", dns.TypeA)
+
+			// the client doesn't verify NOTAUTH responses
+			resp, err := requestSigned(msg, tsigKeyName, tsigSecret)
+			Expect(err).Should(MatchError(dns.ErrAuth))
+			Expect(resp.IsTsig().MAC).ShouldNot(BeEmpty())
+			Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+			Expect(resp.IsTsig().Error).Should(Equal(uint16(dns.RcodeBadTime)))
+			Expect(resp.IsTsig().OtherLen).Should(BeNumerically("==", 6))
+		})
+
+		It("should sign the response with a wrong MAC", func() {
+			msg.SetQuestion("bad3.test.", dns.TypeA)
+
+			resp, err := requestSigned(msg, tsigKeyName, tsigSecret)
+			Expect(err).Should(MatchError(dns.ErrSig))
+			Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+			Expect(resp.Answer).Should(BeDNSRecord("bad3.test.", dns.TypeA, 1, "192.0.2.3"))
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// handleUpdate answers dynamic update (RFC 2136) requests, the zone section is matched against the rules
+// with the "update" opcode
+func (s *Server) handleUpdate(rw dns.ResponseWriter, request *dns.Msg, ctx *requestContext) {
+	rCode, drop := s.update(request, ctx)
+	if drop {
+		return
+	}
+
+	response := new(dns.Msg)
+	response.SetRcode(request, rCode)
+
+	s.writeResponse(rw, request, response, ctx)
+}
+
+// update applies the update to the zone of the matching rule and returns the response code.
+// Rules without zone answer only with their response code, drop is set if the rule doesn't answer (DROP()).
+func (s *Server) update(request *dns.Msg, ctx *requestContext) (rCode int, drop bool) {
+	if len(request.Question) != 1 || request.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError, false
+	}
+
+	question := request.Question[0]
+
+	rule := s.matchRule(question, ctx)
+	if rule == nil {
+		return dns.RcodeNotAuth, false
+	}
+
+	result := s.executeRule(rule)
+	if result.Drop {
+		return 0, true
+	}
+
+	if result.RCode != dns.RcodeSuccess || len(result.ZoneFiles) == 0 {
+		return result.RCode, false
+	}
+
+	rCode, err := s.zones.update(result.ZoneFiles, question.Name, request.Answer, request.Ns)
+	if err != nil {
+		log.Errorf("can't load zone of rule '%s': %v", rule.Name, err)
+	}
+
+	return rCode, false
+}
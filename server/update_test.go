@@ -0,0 +1,89 @@
+package server
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const updateZone = `$ORIGIN ddns.test.
+$TTL 300
+@    IN SOA   ns1 hostmaster 1 3600 600 86400 60
+@    IN NS    ns1
+ns1  IN A     192.0.2.1
+www  IN A     192.0.2.10
+`
+
+func newRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	Expect(err).Should(Succeed())
+
+	return rr
+}
+
+var _ = Describe("Dynamic update", func() {
+	It("should apply the update to the zone", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("ddns.test.")
+		msg.Insert([]dns.RR{newRR(`_acme-challenge.ddns.test. 60 IN TXT "token"`)})
+
+		resp, err := requestServer(msg, "tcp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Opcode).Should(Equal(dns.OpcodeUpdate))
+
+		query := new(dns.Msg)
+		query.SetQuestion("_acme-challenge.ddns.test.", dns.TypeTXT)
+
+		resp, err = requestServer(query, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Answer).Should(HaveLen(1))
+		Expect(resp.Answer[0].(*dns.TXT).Txt).Should(Equal([]string{"token"}))
+
+		query.SetQuestion("ddns.test.", dns.TypeSOA)
+
+		resp, err = requestServer(query, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Answer[0].(*dns.SOA).Serial).Should(BeNumerically(">", 1))
+	})
+
+	It("should check the prerequisites", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("ddns.test.")
+		msg.RRsetUsed([]dns.RR{newRR("www.ddns.test. 0 IN AAAA ::")})
+		msg.Insert([]dns.RR{newRR("www.ddns.test. 300 IN AAAA 2001:db8::10")})
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNXRrset))
+	})
+
+	It("should return the response code of the rule", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("refused.test.")
+		msg.Insert([]dns.RR{newRR("www.refused.test. 300 IN A 192.0.2.1")})
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeRefused))
+	})
+
+	It("should return NOTAUTH if no rule matches", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("other.test.")
+		msg.Insert([]dns.RR{newRR("www.other.test. 300 IN A 192.0.2.1")})
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+	})
+
+	It("should not apply query rules to updates", func() {
+		msg := new(dns.Msg)
+		msg.SetUpdate("www.ddns.test.")
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNotAuth))
+	})
+})
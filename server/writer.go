@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// bufferedResponseWriter is a dns.ResponseWriter which keeps the response in memory.
+// It is used to pass requests of other transports (DoH, DoQ) to the OnRequest handler.
+type bufferedResponseWriter struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	response   *dns.Msg
+	closed     bool
+}
+
+func newBufferedResponseWriter(localAddr, remoteAddr net.Addr) *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+	}
+}
+
+func (w *bufferedResponseWriter) LocalAddr() net.Addr {
+	return w.localAddr
+}
+
+func (w *bufferedResponseWriter) RemoteAddr() net.Addr {
+	return w.remoteAddr
+}
+
+func (w *bufferedResponseWriter) WriteMsg(msg *dns.Msg) error {
+	w.response = msg
+
+	return nil
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(b); err != nil {
+		return 0, err
+	}
+
+	w.response = msg
+
+	return len(b), nil
+}
+
+func (w *bufferedResponseWriter) Close() error {
+	w.closed = true
+
+	return nil
+}
+
+// TsigStatus returns an error, because the signature of the raw message can't be verified
+func (w *bufferedResponseWriter) TsigStatus() error {
+	return errTSIGNotSupported
+}
+
+func (w *bufferedResponseWriter) TsigTimersOnly(bool) {}
+
+func (w *bufferedResponseWriter) Hijack() {}
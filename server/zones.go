@@ -0,0 +1,153 @@
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/0xERR0R/dns-mokka/config"
+	"github.com/0xERR0R/dns-mokka/zone"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// zoneStore keeps the zones of the rules in memory, the zone files are loaded on first use.
+// A zone is identified by the file of its current version, so rules with the same current file
+// (for example ZONE("v2.zone") and TRANSFER("v1.zone", "v2.zone")) share dynamic updates and history.
+type zoneStore struct {
+	mu    sync.Mutex
+	zones map[string]*storedZone
+}
+
+// storedZone contains the versions of a zone and the files of the versions before the current file
+type storedZone struct {
+	history zone.History
+	older   []string
+}
+
+func newZoneStore() *zoneStore {
+	return &zoneStore{
+		zones: make(map[string]*storedZone),
+	}
+}
+
+// history returns the versions of the zone from the files
+func (zs *zoneStore) history(files []string) (zone.History, error) {
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+
+	z, err := zs.load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.history, nil
+}
+
+// find returns the current version of a loaded zone with the origin
+func (zs *zoneStore) find(origin string) (*zone.Zone, bool) {
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+
+	for _, z := range zs.zones {
+		if strings.EqualFold(z.history.Current().Origin, dns.Fqdn(origin)) {
+			return z.history.Current(), true
+		}
+	}
+
+	return nil, false
+}
+
+// zoneKey identifies the zone by the file of its current version
+func zoneKey(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	return files[len(files)-1]
+}
+
+// load returns the cached zone or loads the files, the caller must hold the lock.
+// If the files contain more older versions than the cached zone, they are added before the cached versions,
+// so the history keeps the dynamic updates.
+func (zs *zoneStore) load(files []string) (*storedZone, error) {
+	key := zoneKey(files)
+
+	z, ok := zs.zones[key]
+	if !ok {
+		h, err := zone.LoadHistory(files...)
+		if err != nil {
+			return nil, err
+		}
+
+		z = &storedZone{history: h, older: files[:len(files)-1]}
+		zs.zones[key] = z
+
+		return z, nil
+	}
+
+	older := files[:len(files)-1]
+	if len(older) <= len(z.older) {
+		return z, nil
+	}
+
+	versions, err := zone.LoadHistory(older...)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := zone.NewHistory(append(versions, z.history[len(z.older):]...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	z.history, z.older = h, older
+
+	return z, nil
+}
+
+// update applies a dynamic update to the current version of the zone from the files.
+// A changed zone is added as new version, so it is visible for queries and IXFR.
+func (zs *zoneStore) update(files []string, name string, prereqs, updates []dns.RR) (int, error) {
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+
+	z, err := zs.load(files)
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	current := z.history.Current()
+
+	if !strings.EqualFold(dns.Fqdn(name), current.Origin) {
+		return dns.RcodeNotAuth, nil
+	}
+
+	updated, rCode := current.Update(prereqs, updates)
+	if updated != current {
+		z.history = append(z.history[:len(z.history):len(z.history)], updated)
+	}
+
+	return rCode, nil
+}
+
+// lookupZone answers the question with the current version of the zone of the rule
+func (s *Server) lookupZone(rule *config.Rule, files []string, question dns.Question) resolution {
+	history, err := s.zones.history(files)
+	if err != nil {
+		log.Errorf("can't load zone of rule '%s': %v", rule.Name, err)
+
+		return resolution{rCode: dns.RcodeServerFailure}
+	}
+
+	z := history.Current()
+	a := z.Lookup(question.Name, question.Qtype)
+
+	return resolution{
+		rCode:         a.RCode,
+		authoritative: a.Authoritative,
+		answers:       a.Answer,
+		ns:            a.Ns,
+		extra:         a.Extra,
+		zone:          z,
+	}
+}
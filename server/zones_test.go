@@ -0,0 +1,129 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const authoritativeZone = `$ORIGIN mokka.test.
+$TTL 300
+@       IN SOA   ns1 hostmaster 1 3600 600 86400 60
+@       IN NS    ns1
+ns1     IN A     192.0.2.1
+www     IN A     192.0.2.10
+alias   IN CNAME www
+*.wild  IN A     192.0.2.30
+sub     IN NS    ns.sub
+ns.sub  IN A     192.0.2.40
+`
+
+var _ = Describe("Zone rules", func() {
+	query := func(name string, qType uint16) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qType)
+
+		resp, err := requestServer(msg, "udp")
+		Expect(err).Should(Succeed())
+
+		return resp
+	}
+
+	It("should answer authoritatively with the records of the zone", func() {
+		resp := query("www.mokka.test.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Authoritative).Should(BeTrue())
+		Expect(resp.Answer).Should(BeDNSRecord("www.mokka.test.", dns.TypeA, 300, "192.0.2.10"))
+	})
+
+	It("should answer with wildcard records", func() {
+		resp := query("host.wild.mokka.test.", dns.TypeA)
+
+		Expect(resp.Answer).Should(BeDNSRecord("host.wild.mokka.test.", dns.TypeA, 300, "192.0.2.30"))
+	})
+
+	It("should follow CNAME records in the zone", func() {
+		resp := query("alias.mokka.test.", dns.TypeA)
+
+		Expect(resp.Answer).Should(HaveLen(2))
+		Expect(resp.Answer[1]).Should(BeDNSRecord("www.mokka.test.", dns.TypeA, 300, "192.0.2.10"))
+	})
+
+	It("should return NODATA with SOA record", func() {
+		resp := query("www.mokka.test.", dns.TypeTXT)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).Should(BeEmpty())
+		Expect(resp.Ns).Should(HaveLen(1))
+		Expect(resp.Ns[0].Header().Rrtype).Should(Equal(dns.TypeSOA))
+	})
+
+	It("should return NXDOMAIN with SOA record", func() {
+		resp := query("absent.mokka.test.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeNameError))
+		Expect(resp.Authoritative).Should(BeTrue())
+		Expect(resp.Ns).Should(HaveLen(1))
+		Expect(resp.Ns[0].Header().Rrtype).Should(Equal(dns.TypeSOA))
+	})
+
+	It("should refer to delegated subzones", func() {
+		resp := query("host.sub.mokka.test.", dns.TypeA)
+
+		Expect(resp.Rcode).Should(Equal(dns.RcodeSuccess))
+		Expect(resp.Authoritative).Should(BeFalse())
+		Expect(resp.Ns).Should(HaveLen(1))
+		Expect(resp.Extra).Should(BeDNSRecord("ns.sub.mokka.test.", dns.TypeA, 300, "192.0.2.40"))
+	})
+
+	It("should serve the zone for AXFR", func() {
+		msg := new(dns.Msg)
+		msg.SetAxfr("mokka.test.")
+
+		messages, err := transferIn(msg)
+		Expect(err).Should(Succeed())
+		Expect(messages).Should(HaveLen(1))
+		Expect(messages[0]).Should(HaveLen(9))
+	})
+})
+
+var _ = Describe("Zone store", func() {
+	It("should share the updates of rules with the same current zone file", func() {
+		tmpDir := GinkgoT().TempDir()
+
+		v1 := filepath.Join(tmpDir, "v1.zone")
+		Expect(os.WriteFile(v1, []byte(testZone(1, 1)), 0o600)).Should(Succeed())
+
+		v2 := filepath.Join(tmpDir, "v2.zone")
+		Expect(os.WriteFile(v2, []byte(testZone(2, 2)), 0o600)).Should(Succeed())
+
+		zs := newZoneStore()
+
+		// ZONE("v2") is loaded first and updated
+		_, err := zs.history([]string{v2})
+		Expect(err).Should(Succeed())
+
+		added, err := dns.NewRR("new.example.test. 300 IN A 192.0.2.50")
+		Expect(err).Should(Succeed())
+
+		rCode, err := zs.update([]string{v2}, "example.test.", nil, []dns.RR{added})
+		Expect(err).Should(Succeed())
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+
+		// TRANSFER("v1", "v2") sees the update after its older version
+		history, err := zs.history([]string{v1, v2})
+		Expect(err).Should(Succeed())
+		Expect(history).Should(HaveLen(3))
+		Expect(history.Current().Serial()).Should(Equal(uint32(3)))
+		Expect(history.IXFR(1)).Should(ContainElement(added))
+
+		history, err = zs.history([]string{v2})
+		Expect(err).Should(Succeed())
+		Expect(history).Should(HaveLen(3))
+		Expect(history.Current().Lookup("new.example.test.", dns.TypeA).Answer).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,210 @@
+package zone
+
+import (
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// Answer is the response of the zone to a question
+type Answer struct {
+	RCode int
+	// Authoritative is false for referrals to delegated subzones
+	Authoritative bool
+	Answer        []dns.RR
+	Ns            []dns.RR
+	Extra         []dns.RR
+}
+
+// Lookup answers the question with the records of the zone like an authoritative server (RFC 1034, 4.3.2):
+// referrals with glue records for delegated subzones, wildcard records, NXDOMAIN for unknown names and
+// NODATA for known names without records of the type. CNAME records are returned, but not followed.
+func (z *Zone) Lookup(name string, qType uint16) Answer {
+	owner := dns.CanonicalName(name)
+
+	if !dns.IsSubDomain(z.Origin, owner) {
+		return Answer{RCode: dns.RcodeRefused}
+	}
+
+	if cut := z.zoneCut(owner, qType); cut != "" {
+		return z.referral(cut)
+	}
+
+	if z.nodes[owner] {
+		return z.answer(owner, name, qType)
+	}
+
+	if wildcard := z.wildcard(owner); wildcard != "" {
+		return z.answer(wildcard, name, qType)
+	}
+
+	return z.negative(dns.RcodeNameError)
+}
+
+// zoneCut returns the topmost delegation (NS records below the apex) above or at the name or empty string.
+// DS records at the zone cut belong to the parent zone.
+func (z *Zone) zoneCut(name string, qType uint16) string {
+	var ancestors []string
+
+	for n := name; n != z.Origin; n = parentName(n) {
+		ancestors = append(ancestors, n)
+	}
+
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		n := ancestors[i]
+
+		if n == name && qType == dns.TypeDS {
+			continue
+		}
+
+		if len(filterType(z.owners[n], dns.TypeNS)) > 0 {
+			return n
+		}
+	}
+
+	return ""
+}
+
+// Names returns the record types of each authoritative name of the zone, including the apex and empty
+// non-terminals (without types). Names below a zone cut are omitted, the types of a zone cut are NS and DS.
+func (z *Zone) Names() map[string][]uint16 {
+	names := make(map[string][]uint16, len(z.nodes))
+
+	for n := range z.nodes {
+		if z.zoneCut(n, dns.TypeDS) != "" {
+			continue
+		}
+
+		cut := n != z.Origin && len(filterType(z.owners[n], dns.TypeNS)) > 0
+		types := []uint16{}
+
+		if n == z.Origin {
+			types = append(types, dns.TypeSOA)
+		}
+
+		for _, rr := range z.owners[n] {
+			t := rr.Header().Rrtype
+
+			if cut && t != dns.TypeNS && t != dns.TypeDS {
+				continue
+			}
+
+			if !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		}
+
+		names[n] = types
+	}
+
+	return names
+}
+
+// referral returns the NS records of the delegation and the glue records of name servers inside the zone
+func (z *Zone) referral(cut string) Answer {
+	ns := filterType(z.owners[cut], dns.TypeNS)
+
+	var extra []dns.RR
+
+	for _, rr := range ns {
+		target := dns.CanonicalName(rr.(*dns.NS).Ns)
+		if !dns.IsSubDomain(z.Origin, target) {
+			continue
+		}
+
+		extra = append(extra, filterType(z.owners[target], dns.TypeA)...)
+		extra = append(extra, filterType(z.owners[target], dns.TypeAAAA)...)
+	}
+
+	return Answer{
+		RCode: dns.RcodeSuccess,
+		Ns:    ns,
+		Extra: extra,
+	}
+}
+
+// wildcard returns the wildcard name at the closest encloser of the name or empty string if it doesn't exist
+func (z *Zone) wildcard(name string) string {
+	encloser := parentName(name)
+
+	for !z.nodes[encloser] {
+		encloser = parentName(encloser)
+	}
+
+	wildcard := "*." + encloser
+	if _, ok := z.owners[wildcard]; ok {
+		return wildcard
+	}
+
+	return ""
+}
+
+// answer returns the records of the owner with the query type or CNAME records, records of a wildcard owner
+// are synthesized with the name of the question
+func (z *Zone) answer(owner, name string, qType uint16) Answer {
+	var records []dns.RR
+
+	if owner == z.Origin && (qType == dns.TypeSOA || qType == dns.TypeANY) {
+		records = append(records, z.SOA)
+	}
+
+	for _, rr := range z.owners[owner] {
+		t := rr.Header().Rrtype
+		if qType == dns.TypeANY || t == qType || t == dns.TypeCNAME {
+			records = append(records, rr)
+		}
+	}
+
+	if len(records) == 0 {
+		return z.negative(dns.RcodeSuccess)
+	}
+
+	if owner != dns.CanonicalName(name) {
+		for i, rr := range records {
+			records[i] = dns.Copy(rr)
+			records[i].Header().Name = name
+		}
+	}
+
+	return Answer{
+		RCode:         dns.RcodeSuccess,
+		Authoritative: true,
+		Answer:        records,
+	}
+}
+
+// negative returns a NXDOMAIN or NODATA answer with the SOA record in the authority section,
+// its TTL is the minimum of the SOA TTL and the SOA minimum field (RFC 2308)
+func (z *Zone) negative(rCode int) Answer {
+	soa := dns.Copy(z.SOA).(*dns.SOA)
+	soa.Hdr.Ttl = min(soa.Hdr.Ttl, soa.Minttl)
+
+	return Answer{
+		RCode:         rCode,
+		Authoritative: true,
+		Ns:            []dns.RR{soa},
+	}
+}
+
+// filterType returns the records with the type
+func filterType(records []dns.RR, rType uint16) []dns.RR {
+	var result []dns.RR
+
+	for _, rr := range records {
+		if rr.Header().Rrtype == rType {
+			result = append(result, rr)
+		}
+	}
+
+	return result
+}
+
+// parentName returns the name without the first label
+func parentName(name string) string {
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return "."
+	}
+
+	return name[off:]
+}
@@ -0,0 +1,133 @@
+package zone
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const lookupZone = `$ORIGIN example.test.
+$TTL 300
+@              IN SOA   ns1 hostmaster 1 3600 600 86400 60
+@              IN NS    ns1
+@              IN MX    10 mail
+ns1            IN A     192.0.2.1
+www            IN A     192.0.2.10
+www            IN AAAA  2001:db8::10
+alias          IN CNAME www
+a.b.deep       IN A     192.0.2.20
+*.wild         IN A     192.0.2.30
+sub            IN NS    ns.sub
+sub            IN DS    12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF
+ns.sub         IN A     192.0.2.40
+`
+
+var _ = Describe("Lookup", func() {
+	var z *Zone
+
+	BeforeEach(func() {
+		var err error
+		z, err = Parse(strings.NewReader(lookupZone), "test")
+		Expect(err).Should(Succeed())
+	})
+
+	It("should return the records of the name and type", func() {
+		a := z.Lookup("WWW.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(a.Authoritative).Should(BeTrue())
+		Expect(names(a.Answer)).Should(Equal([]string{"www.example.test. A"}))
+	})
+
+	It("should return all records for ANY queries", func() {
+		a := z.Lookup("www.example.test.", dns.TypeANY)
+
+		Expect(a.Answer).Should(HaveLen(2))
+	})
+
+	It("should return the SOA record of the apex", func() {
+		a := z.Lookup("example.test.", dns.TypeSOA)
+
+		Expect(names(a.Answer)).Should(Equal([]string{"example.test. SOA"}))
+	})
+
+	It("should return the CNAME record for other types", func() {
+		a := z.Lookup("alias.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(names(a.Answer)).Should(Equal([]string{"alias.example.test. CNAME"}))
+	})
+
+	It("should return NODATA for existing names without records of the type", func() {
+		a := z.Lookup("www.example.test.", dns.TypeTXT)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(a.Answer).Should(BeEmpty())
+		Expect(names(a.Ns)).Should(Equal([]string{"example.test. SOA"}))
+		Expect(a.Ns[0].Header().Ttl).Should(BeNumerically("==", 60))
+	})
+
+	It("should return NODATA for empty non-terminals", func() {
+		a := z.Lookup("b.deep.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(a.Answer).Should(BeEmpty())
+		Expect(a.Ns).Should(HaveLen(1))
+	})
+
+	It("should return NXDOMAIN for unknown names", func() {
+		a := z.Lookup("unknown.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeNameError))
+		Expect(a.Authoritative).Should(BeTrue())
+		Expect(names(a.Ns)).Should(Equal([]string{"example.test. SOA"}))
+	})
+
+	It("should synthesize wildcard records", func() {
+		a := z.Lookup("Foo.wild.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(names(a.Answer)).Should(Equal([]string{"Foo.wild.example.test. A"}))
+	})
+
+	It("should not apply wildcards below the closest encloser", func() {
+		a := z.Lookup("foo.www.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeNameError))
+	})
+
+	It("should refer to delegated subzones with glue records", func() {
+		a := z.Lookup("host.sub.example.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeSuccess))
+		Expect(a.Authoritative).Should(BeFalse())
+		Expect(a.Answer).Should(BeEmpty())
+		Expect(names(a.Ns)).Should(Equal([]string{"sub.example.test. NS"}))
+		Expect(names(a.Extra)).Should(Equal([]string{"ns.sub.example.test. A"}))
+	})
+
+	It("should answer DS records of the delegation", func() {
+		a := z.Lookup("sub.example.test.", dns.TypeDS)
+
+		Expect(a.Authoritative).Should(BeTrue())
+		Expect(names(a.Answer)).Should(Equal([]string{"sub.example.test. DS"}))
+	})
+
+	It("should refuse names outside of the zone", func() {
+		a := z.Lookup("www.other.test.", dns.TypeA)
+
+		Expect(a.RCode).Should(Equal(dns.RcodeRefused))
+	})
+
+	It("should return the types of the authoritative names", func() {
+		names := z.Names()
+
+		Expect(names).Should(HaveKeyWithValue("example.test.", []uint16{dns.TypeSOA, dns.TypeNS, dns.TypeMX}))
+		Expect(names).Should(HaveKeyWithValue("www.example.test.", []uint16{dns.TypeA, dns.TypeAAAA}))
+		Expect(names).Should(HaveKeyWithValue("b.deep.example.test.", BeEmpty()))
+		Expect(names).Should(HaveKeyWithValue("sub.example.test.", []uint16{dns.TypeNS, dns.TypeDS}))
+		Expect(names).ShouldNot(HaveKey("ns.sub.example.test."))
+	})
+})
@@ -0,0 +1,255 @@
+package zone
+
+import (
+	"github.com/miekg/dns"
+)
+
+// Update applies a dynamic update (RFC 2136) with the prerequisites and updates to the zone.
+// It returns the new version of the zone with incremented serial or the zone itself if nothing was changed.
+// The zone is not changed if the response code is not NOERROR.
+func (z *Zone) Update(prereqs, updates []dns.RR) (*Zone, int) {
+	if rCode := z.checkPrerequisites(prereqs); rCode != dns.RcodeSuccess {
+		return z, rCode
+	}
+
+	if rCode := z.checkUpdates(updates); rCode != dns.RcodeSuccess {
+		return z, rCode
+	}
+
+	records := make([]dns.RR, len(z.Records))
+	copy(records, z.Records)
+
+	changed := false
+
+	for _, u := range updates {
+		var c bool
+
+		records, c = z.applyUpdate(records, u)
+		changed = changed || c
+	}
+
+	if !changed {
+		return z, dns.RcodeSuccess
+	}
+
+	soa := dns.Copy(z.SOA).(*dns.SOA)
+	soa.Serial++
+
+	updated, err := New(soa, records)
+	if err != nil {
+		return z, dns.RcodeServerFailure
+	}
+
+	return updated, dns.RcodeSuccess
+}
+
+// checkPrerequisites checks the prerequisite section (RFC 2136, 3.2)
+func (z *Zone) checkPrerequisites(prereqs []dns.RR) int {
+	for _, rr := range prereqs {
+		h := rr.Header()
+		name := dns.CanonicalName(h.Name)
+
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+
+		if !dns.IsSubDomain(z.Origin, name) {
+			return dns.RcodeNotZone
+		}
+
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rrtype == dns.TypeANY && !z.nameInUse(name) {
+				return dns.RcodeNameError
+			}
+
+			if h.Rrtype != dns.TypeANY && len(z.rrset(name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeANY && z.nameInUse(name) {
+				return dns.RcodeYXDomain
+			}
+
+			if h.Rrtype != dns.TypeANY && len(z.rrset(name, h.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			if !z.rrsetEquals(name, h.Rrtype, prereqs) {
+				return dns.RcodeNXRrset
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// checkUpdates checks the update section before any record is changed (RFC 2136, 3.4.1)
+func (z *Zone) checkUpdates(updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+
+		if !dns.IsSubDomain(z.Origin, dns.CanonicalName(h.Name)) {
+			return dns.RcodeNotZone
+		}
+
+		switch h.Class {
+		case dns.ClassINET:
+			if h.Rrtype == dns.TypeANY || h.Rrtype == dns.TypeAXFR || h.Rrtype == dns.TypeIXFR {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY, dns.ClassNONE:
+			if h.Ttl != 0 {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// applyUpdate applies one record of the update section (RFC 2136, 3.4.2), SOA records are managed by the zone
+func (z *Zone) applyUpdate(records []dns.RR, u dns.RR) ([]dns.RR, bool) {
+	h := u.Header()
+	name := dns.CanonicalName(h.Name)
+	apex := name == z.Origin
+
+	if h.Rrtype == dns.TypeSOA {
+		return records, false
+	}
+
+	switch h.Class {
+	case dns.ClassINET:
+		return addRecord(records, u)
+	case dns.ClassANY:
+		return removeRecords(records, func(rr dns.RR) bool {
+			t := rr.Header().Rrtype
+
+			return dns.CanonicalName(rr.Header().Name) == name &&
+				(h.Rrtype == dns.TypeANY || t == h.Rrtype) &&
+				!(apex && t == dns.TypeNS)
+		})
+	case dns.ClassNONE:
+		if apex && h.Rrtype == dns.TypeNS && len(filterOwner(records, name, dns.TypeNS)) <= 1 {
+			return records, false
+		}
+
+		key := rdataKey(u)
+
+		return removeRecords(records, func(rr dns.RR) bool {
+			return rdataKey(rr) == key
+		})
+	}
+
+	return records, false
+}
+
+// addRecord adds the record, a record with the same data is replaced (new TTL).
+// CNAME records and other records can't exist at the same name.
+func addRecord(records []dns.RR, u dns.RR) ([]dns.RR, bool) {
+	name := dns.CanonicalName(u.Header().Name)
+	key := rdataKey(u)
+
+	for i, rr := range records {
+		if dns.CanonicalName(rr.Header().Name) != name {
+			continue
+		}
+
+		isCNAME := rr.Header().Rrtype == dns.TypeCNAME
+
+		if isCNAME != (u.Header().Rrtype == dns.TypeCNAME) {
+			return records, false
+		}
+
+		if isCNAME || rdataKey(rr) == key {
+			if rr.String() == u.String() {
+				return records, false
+			}
+
+			records[i] = u
+
+			return records, true
+		}
+	}
+
+	return append(records, u), true
+}
+
+// removeRecords removes all records which match
+func removeRecords(records []dns.RR, match func(rr dns.RR) bool) ([]dns.RR, bool) {
+	result := records[:0]
+
+	for _, rr := range records {
+		if !match(rr) {
+			result = append(result, rr)
+		}
+	}
+
+	return result, len(result) != len(records)
+}
+
+// nameInUse checks if the name owns at least one record
+func (z *Zone) nameInUse(name string) bool {
+	return name == z.Origin || len(z.owners[name]) > 0
+}
+
+// rrset returns the records of the name with the type
+func (z *Zone) rrset(name string, rType uint16) []dns.RR {
+	if name == z.Origin && rType == dns.TypeSOA {
+		return []dns.RR{z.SOA}
+	}
+
+	return filterType(z.owners[name], rType)
+}
+
+// rrsetEquals checks if the RRset of the name and type in the zone is equal to the records of the prerequisites
+func (z *Zone) rrsetEquals(name string, rType uint16, prereqs []dns.RR) bool {
+	expected := make(map[string]bool)
+
+	for _, rr := range prereqs {
+		if rr.Header().Class == dns.ClassINET && rr.Header().Rrtype == rType &&
+			dns.CanonicalName(rr.Header().Name) == name {
+			expected[rdataKey(rr)] = true
+		}
+	}
+
+	actual := z.rrset(name, rType)
+	if len(actual) != len(expected) {
+		return false
+	}
+
+	for _, rr := range actual {
+		if !expected[rdataKey(rr)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterOwner returns the records of the name with the type
+func filterOwner(records []dns.RR, name string, rType uint16) []dns.RR {
+	var result []dns.RR
+
+	for _, rr := range records {
+		if rr.Header().Rrtype == rType && dns.CanonicalName(rr.Header().Name) == name {
+			result = append(result, rr)
+		}
+	}
+
+	return result
+}
+
+// rdataKey identifies a record by name, type and data, the TTL and class are ignored
+func rdataKey(rr dns.RR) string {
+	c := dns.Copy(rr)
+	c.Header().Name = dns.CanonicalName(c.Header().Name)
+	c.Header().Ttl = 0
+	c.Header().Class = dns.ClassINET
+
+	return c.String()
+}
@@ -0,0 +1,145 @@
+package zone
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const updateZone = `$ORIGIN example.test.
+$TTL 300
+@      IN SOA   ns1 hostmaster 1 3600 600 86400 60
+@      IN NS    ns1
+ns1    IN A     192.0.2.1
+www    IN A     192.0.2.10
+www    IN A     192.0.2.11
+alias  IN CNAME www
+`
+
+func rr(s string) dns.RR {
+	r, err := dns.NewRR(s)
+	Expect(err).Should(Succeed())
+
+	return r
+}
+
+var _ = Describe("Update", func() {
+	var (
+		z   *Zone
+		msg *dns.Msg
+	)
+
+	BeforeEach(func() {
+		var err error
+		z, err = Parse(strings.NewReader(updateZone), "test")
+		Expect(err).Should(Succeed())
+
+		msg = new(dns.Msg)
+		msg.SetUpdate("example.test.")
+	})
+
+	update := func() (*Zone, int) {
+		return z.Update(msg.Answer, msg.Ns)
+	}
+
+	It("should add records and increment the serial", func() {
+		msg.Insert([]dns.RR{rr("_acme-challenge.example.test. 60 IN TXT \"token\"")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated.Serial()).Should(BeNumerically("==", 2))
+		Expect(updated.Lookup("_acme-challenge.example.test.", dns.TypeTXT).Answer).Should(HaveLen(1))
+		Expect(z.Lookup("_acme-challenge.example.test.", dns.TypeTXT).RCode).Should(Equal(dns.RcodeNameError))
+	})
+
+	It("should not change the zone if the record exists", func() {
+		msg.Insert([]dns.RR{rr("www.example.test. 300 IN A 192.0.2.10")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated).Should(BeIdenticalTo(z))
+	})
+
+	It("should delete a RRset", func() {
+		msg.RemoveRRset([]dns.RR{rr("www.example.test. 0 IN A 0.0.0.0")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated.Lookup("www.example.test.", dns.TypeA).Answer).Should(BeEmpty())
+	})
+
+	It("should delete a single record", func() {
+		msg.Remove([]dns.RR{rr("www.example.test. 300 IN A 192.0.2.10")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated.Lookup("www.example.test.", dns.TypeA).Answer).Should(HaveLen(1))
+	})
+
+	It("should delete all records of a name", func() {
+		msg.RemoveName([]dns.RR{rr("alias.example.test. 0 IN A 0.0.0.0")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated.Lookup("alias.example.test.", dns.TypeA).RCode).Should(Equal(dns.RcodeNameError))
+	})
+
+	It("should not delete the last NS record of the apex", func() {
+		msg.Remove([]dns.RR{rr("example.test. 300 IN NS ns1.example.test.")})
+
+		updated, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeSuccess))
+		Expect(updated.Lookup("example.test.", dns.TypeNS).Answer).Should(HaveLen(1))
+	})
+
+	It("should not add other records to a CNAME", func() {
+		msg.Insert([]dns.RR{rr("alias.example.test. 300 IN A 192.0.2.99")})
+
+		updated, _ := update()
+		Expect(updated).Should(BeIdenticalTo(z))
+	})
+
+	It("should return NOTZONE for records outside of the zone", func() {
+		msg.Insert([]dns.RR{rr("www.other.test. 300 IN A 192.0.2.99")})
+
+		_, rCode := update()
+		Expect(rCode).Should(Equal(dns.RcodeNotZone))
+	})
+
+	DescribeTable("prerequisites",
+		func(prepare func(), expected int) {
+			prepare()
+			msg.Insert([]dns.RR{rr("new.example.test. 300 IN A 192.0.2.99")})
+
+			updated, rCode := update()
+			Expect(rCode).Should(Equal(expected))
+
+			if expected != dns.RcodeSuccess {
+				Expect(updated).Should(BeIdenticalTo(z))
+			}
+		},
+		Entry("name is in use", func() {
+			msg.NameUsed([]dns.RR{rr("www.example.test. 0 IN A 0.0.0.0")})
+		}, dns.RcodeSuccess),
+		Entry("name is not in use", func() {
+			msg.NameUsed([]dns.RR{rr("unknown.example.test. 0 IN A 0.0.0.0")})
+		}, dns.RcodeNameError),
+		Entry("name is unused", func() {
+			msg.NameNotUsed([]dns.RR{rr("www.example.test. 0 IN A 0.0.0.0")})
+		}, dns.RcodeYXDomain),
+		Entry("RRset exists", func() {
+			msg.RRsetUsed([]dns.RR{rr("www.example.test. 0 IN AAAA ::")})
+		}, dns.RcodeNXRrset),
+		Entry("RRset doesn't exist", func() {
+			msg.RRsetNotUsed([]dns.RR{rr("www.example.test. 0 IN A 0.0.0.0")})
+		}, dns.RcodeYXRrset),
+		Entry("RRset has the values", func() {
+			msg.Used([]dns.RR{rr("www.example.test. 0 IN A 192.0.2.10"), rr("www.example.test. 0 IN A 192.0.2.11")})
+		}, dns.RcodeSuccess),
+		Entry("RRset has other values", func() {
+			msg.Used([]dns.RR{rr("www.example.test. 0 IN A 192.0.2.10")})
+		}, dns.RcodeNXRrset),
+	)
+})
@@ -0,0 +1,233 @@
+package zone
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// Zone contains the records of a zone in the master file format (RFC 1035)
+type Zone struct {
+	// Origin is the lower case name of the zone apex
+	Origin string
+	SOA    *dns.SOA
+	// Records contains all records of the zone except the SOA record
+	Records []dns.RR
+
+	// owners contains the records by lower case owner name
+	owners map[string][]dns.RR
+	// nodes contains all names of the zone including empty non-terminals
+	nodes map[string]bool
+}
+
+// New creates a zone with the SOA record and the other records
+func New(soa *dns.SOA, records []dns.RR) (*Zone, error) {
+	z := &Zone{
+		Origin:  dns.CanonicalName(soa.Hdr.Name),
+		SOA:     soa,
+		Records: records,
+		owners:  make(map[string][]dns.RR),
+		nodes:   map[string]bool{dns.CanonicalName(soa.Hdr.Name): true},
+	}
+
+	for _, rr := range records {
+		name := dns.CanonicalName(rr.Header().Name)
+
+		if !dns.IsSubDomain(z.Origin, name) {
+			return nil, fmt.Errorf("record '%s' is outside of zone '%s'", rr, z.Origin)
+		}
+
+		z.owners[name] = append(z.owners[name], rr)
+
+		for n := name; n != z.Origin && !z.nodes[n]; n = parentName(n) {
+			z.nodes[n] = true
+		}
+	}
+
+	return z, nil
+}
+
+// Load reads the zone from the master file
+func Load(path string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open zone file: %w", err)
+	}
+
+	defer f.Close()
+
+	return Parse(f, path)
+}
+
+// Parse reads a zone in the master file format, the origin of the zone is the owner of the SOA record
+func Parse(r io.Reader, file string) (*Zone, error) {
+	var (
+		soa     *dns.SOA
+		records []dns.RR
+	)
+
+	zp := dns.NewZoneParser(r, "", file)
+	zp.SetIncludeAllowed(true)
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if s, isSOA := rr.(*dns.SOA); isSOA {
+			if soa != nil {
+				return nil, fmt.Errorf("zone '%s' has more than one SOA record", file)
+			}
+
+			soa = s
+
+			continue
+		}
+
+		records = append(records, rr)
+	}
+
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("can't parse zone '%s': %w", file, err)
+	}
+
+	if soa == nil {
+		return nil, fmt.Errorf("zone '%s' has no SOA record", file)
+	}
+
+	return New(soa, records)
+}
+
+// Serial returns the serial of the SOA record
+func (z *Zone) Serial() uint32 {
+	return z.SOA.Serial
+}
+
+// AXFR returns the records of a full zone transfer (RFC 5936): SOA, all other records and SOA again
+func (z *Zone) AXFR() []dns.RR {
+	records := make([]dns.RR, 0, len(z.Records)+2)
+
+	records = append(records, z.SOA)
+	records = append(records, z.Records...)
+
+	return append(records, z.SOA)
+}
+
+// Diff returns the records which were deleted and added in the newer version of the zone
+func (z *Zone) Diff(newer *Zone) (deleted, added []dns.RR) {
+	return subtract(z.Records, newer.Records), subtract(newer.Records, z.Records)
+}
+
+// subtract returns the records of a which are not in b, a changed TTL is a different record
+func subtract(a, b []dns.RR) []dns.RR {
+	keys := make(map[string]bool, len(b))
+
+	for _, rr := range b {
+		keys[recordKey(rr)] = true
+	}
+
+	var result []dns.RR
+
+	for _, rr := range a {
+		if !keys[recordKey(rr)] {
+			result = append(result, rr)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return dns.CanonicalName(result[i].Header().Name) < dns.CanonicalName(result[j].Header().Name)
+	})
+
+	return result
+}
+
+func recordKey(rr dns.RR) string {
+	c := dns.Copy(rr)
+	c.Header().Name = dns.CanonicalName(c.Header().Name)
+
+	return c.String()
+}
+
+// History contains the versions of a zone, ordered from the oldest to the current version
+type History []*Zone
+
+// LoadHistory reads the versions of a zone from the master files, ordered from the oldest to the current version
+func LoadHistory(files ...string) (History, error) {
+	versions := make([]*Zone, 0, len(files))
+
+	for _, f := range files {
+		z, err := Load(f)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, z)
+	}
+
+	return NewHistory(versions...)
+}
+
+// NewHistory checks that all versions belong to the same zone and have distinct serials
+func NewHistory(versions ...*Zone) (History, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("zone history is empty")
+	}
+
+	serials := make(map[uint32]bool, len(versions))
+
+	for _, v := range versions {
+		if v.Origin != versions[0].Origin {
+			return nil, fmt.Errorf("zone versions have different origins '%s' and '%s'", versions[0].Origin, v.Origin)
+		}
+
+		if serials[v.Serial()] {
+			return nil, fmt.Errorf("zone '%s' has multiple versions with serial %d", v.Origin, v.Serial())
+		}
+
+		serials[v.Serial()] = true
+	}
+
+	return versions, nil
+}
+
+// Current returns the latest version of the zone
+func (h History) Current() *Zone {
+	return h[len(h)-1]
+}
+
+// IXFR returns the records of an incremental zone transfer (RFC 1995) from the version with the serial
+// to the current version. If the serial is the current one, only the SOA record is returned.
+// If the serial is unknown, the records of a full zone transfer are returned.
+func (h History) IXFR(serial uint32) []dns.RR {
+	current := h.Current()
+
+	if serial == current.Serial() {
+		return []dns.RR{current.SOA}
+	}
+
+	start := -1
+
+	for i, v := range h {
+		if v.Serial() == serial {
+			start = i
+
+			break
+		}
+	}
+
+	if start < 0 {
+		return current.AXFR()
+	}
+
+	records := []dns.RR{current.SOA}
+
+	for i := start; i < len(h)-1; i++ {
+		deleted, added := h[i].Diff(h[i+1])
+
+		records = append(records, h[i].SOA)
+		records = append(records, deleted...)
+		records = append(records, h[i+1].SOA)
+		records = append(records, added...)
+	}
+
+	return append(records, current.SOA)
+}
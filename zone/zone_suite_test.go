@@ -0,0 +1,13 @@
+package zone
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestZone(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Zone Suite")
+}
@@ -0,0 +1,173 @@
+package zone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	zoneV1 = `$ORIGIN example.test.
+$TTL 300
+@    IN SOA ns1 hostmaster 1 3600 600 86400 300
+@    IN NS  ns1
+ns1  IN A   192.0.2.1
+www  IN A   192.0.2.10
+`
+	zoneV2 = `$ORIGIN example.test.
+$TTL 300
+@    IN SOA ns1 hostmaster 2 3600 600 86400 300
+@    IN NS  ns1
+ns1  IN A   192.0.2.1
+www  IN A   192.0.2.20
+mail IN A   192.0.2.25
+`
+	zoneV3 = `$ORIGIN example.test.
+$TTL 300
+@    IN SOA ns1 hostmaster 3 3600 600 86400 300
+@    IN NS  ns1
+ns1  IN A   192.0.2.1
+www  IN A   192.0.2.20
+`
+)
+
+func parse(in string) *Zone {
+	z, err := Parse(strings.NewReader(in), "test")
+	Expect(err).Should(Succeed())
+
+	return z
+}
+
+func names(records []dns.RR) []string {
+	result := make([]string, len(records))
+	for i, rr := range records {
+		result[i] = rr.Header().Name + " " + dns.TypeToString[rr.Header().Rrtype]
+	}
+
+	return result
+}
+
+var _ = Describe("Zone", func() {
+	Describe("Parse", func() {
+		It("should parse the master file", func() {
+			z := parse(zoneV1)
+
+			Expect(z.Origin).Should(Equal("example.test."))
+			Expect(z.Serial()).Should(BeNumerically("==", 1))
+			Expect(z.Records).Should(HaveLen(3))
+		})
+
+		It("should fail without SOA record", func() {
+			_, err := Parse(strings.NewReader("www.example.test. 300 IN A 192.0.2.1"), "test")
+
+			Expect(err).Should(MatchError(ContainSubstring("no SOA record")))
+		})
+
+		It("should fail with multiple SOA records", func() {
+			_, err := Parse(strings.NewReader(zoneV1+"@ IN SOA ns1 hostmaster 2 3600 600 86400 300\n"), "test")
+
+			Expect(err).Should(MatchError(ContainSubstring("more than one SOA record")))
+		})
+
+		It("should fail with records outside of the zone", func() {
+			_, err := Parse(strings.NewReader(zoneV1+"www.other.test. IN A 192.0.2.1\n"), "test")
+
+			Expect(err).Should(MatchError(ContainSubstring("outside of zone")))
+		})
+
+		It("should fail with invalid records", func() {
+			_, err := Parse(strings.NewReader(zoneV1+"www IN A 192.0.2\n"), "test")
+
+			Expect(err).Should(MatchError(ContainSubstring("can't parse zone")))
+		})
+	})
+
+	Describe("Load", func() {
+		It("should load the zone file", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "example.test.zone")
+			Expect(os.WriteFile(path, []byte(zoneV1), 0o600)).Should(Succeed())
+
+			z, err := Load(path)
+			Expect(err).Should(Succeed())
+			Expect(z.Origin).Should(Equal("example.test."))
+		})
+
+		It("should fail if the file doesn't exist", func() {
+			_, err := Load("/does/not/exist.zone")
+
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("AXFR", func() {
+		It("should start and end with the SOA record", func() {
+			records := parse(zoneV1).AXFR()
+
+			Expect(names(records)).Should(Equal([]string{
+				"example.test. SOA",
+				"example.test. NS",
+				"ns1.example.test. A",
+				"www.example.test. A",
+				"example.test. SOA",
+			}))
+		})
+	})
+
+	Describe("History", func() {
+		var history History
+
+		BeforeEach(func() {
+			var err error
+			history, err = NewHistory(parse(zoneV1), parse(zoneV2), parse(zoneV3))
+			Expect(err).Should(Succeed())
+		})
+
+		It("should reject versions of different zones", func() {
+			_, err := NewHistory(parse(zoneV1), parse(strings.ReplaceAll(zoneV2, "example.test.", "other.test.")))
+
+			Expect(err).Should(MatchError(ContainSubstring("different origins")))
+		})
+
+		It("should reject duplicate serials", func() {
+			_, err := NewHistory(parse(zoneV1), parse(zoneV1))
+
+			Expect(err).Should(MatchError(ContainSubstring("multiple versions with serial 1")))
+		})
+
+		It("should return only the SOA record for the current serial", func() {
+			records := history.IXFR(3)
+
+			Expect(records).Should(HaveLen(1))
+			Expect(records[0].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+		})
+
+		It("should return a full transfer for unknown serials", func() {
+			records := history.IXFR(42)
+
+			Expect(records).Should(HaveLen(5))
+			Expect(records[0].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+			Expect(records[4].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+		})
+
+		It("should return the differences of all versions since the serial", func() {
+			records := history.IXFR(1)
+
+			Expect(records).Should(HaveLen(10))
+			Expect(records[0].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+			// 1 -> 2: www changed, mail added
+			Expect(records[1].(*dns.SOA).Serial).Should(BeNumerically("==", 1))
+			Expect(records[2].String()).Should(ContainSubstring("192.0.2.10"))
+			Expect(records[3].(*dns.SOA).Serial).Should(BeNumerically("==", 2))
+			Expect(names(records[4:6])).Should(Equal([]string{"mail.example.test. A", "www.example.test. A"}))
+			// 2 -> 3: mail deleted
+			Expect(records[6].(*dns.SOA).Serial).Should(BeNumerically("==", 2))
+			Expect(records[7].String()).Should(ContainSubstring("mail.example.test."))
+			Expect(records[8].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+			Expect(records[9].(*dns.SOA).Serial).Should(BeNumerically("==", 3))
+		})
+	})
+})